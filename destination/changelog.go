@@ -0,0 +1,124 @@
+package destination
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/conduitio/conduit-commons/opencdc"
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/surrealdb/surrealdb.go"
+	"github.com/surrealdb/surrealdb.go/pkg/models"
+)
+
+// Ways changes are logged.
+const (
+	changeLogOff    = "off"
+	changeLogAppend = "append"
+	changeLogOnly   = "only"
+)
+
+// changeLogTable returns the table the changes of a table are appended to.
+func changeLogTable(tableName string) string {
+	return tableName + "_changes"
+}
+
+// logsChanges reports whether changes are appended to a change log.
+func (d *Destination) logsChanges() bool {
+	return d.config.ChangeLog == changeLogAppend || d.config.ChangeLog == changeLogOnly
+}
+
+// changeLogEntries returns the change log entries of the records of a table,
+// given by their positions in recs. Entries hold the record they change, the
+// operation, the payloads before and after the change, the position and the
+// metadata of the record. They have to be built before the records are
+// written, as writing may strip the id from the payloads.
+func (d *Destination) changeLogEntries(tableName string, recs []opencdc.Record, positions []int) ([]map[string]interface{}, error) {
+	entries := make([]map[string]interface{}, len(positions))
+	for i, pos := range positions {
+		r := &recs[pos]
+		afterMap, ok := r.Payload.After.(opencdc.StructuredData)
+		if !ok {
+			return nil, fmt.Errorf("unexpected type for r.Payload.After: %T", r.Payload.After)
+		}
+		if err := d.structuredDataFormatter(&r.Payload.Before); err != nil {
+			return nil, fmt.Errorf("failed to get payload before the change: %w", err)
+		}
+
+		entry := map[string]interface{}{
+			"record":    models.NewRecordID(tableName, afterMap["id"]),
+			"operation": r.Operation.String(),
+			"before":    r.Payload.Before,
+			"position":  positionValue(r.Position),
+			"metadata":  map[string]string(r.Metadata),
+		}
+		// deletes only carry the key as payload
+		if r.Operation != opencdc.OperationDelete {
+			entry["after"] = afterMap.Clone()
+		}
+		if readAt, err := r.Metadata.GetReadAt(); err == nil {
+			entry["read_at"] = datetime(readAt.UTC())
+		}
+		entries[i] = entry
+	}
+	return entries, nil
+}
+
+// changeLogStatements appends the statements that add entries to the change
+// log of a table to query, and adds the variables they use to vars. Entries get
+// a ULID as id, so that they sort in the order they were written.
+func changeLogStatements(query *strings.Builder, vars map[string]interface{}, tableName string, entries []map[string]interface{}) {
+	vars["changes_tb"] = changeLogTable(tableName)
+	for i, entry := range entries {
+		vars[fmt.Sprintf("change%d", i)] = entry
+		fmt.Fprintf(query, "CREATE type::thing($changes_tb, rand::ulid()) CONTENT $change%d;\n", i)
+	}
+}
+
+// loggedWrite writes a run of payloads of a single table like writeRun, unless
+// only changes are logged, and then appends the changes that were written to
+// the change log of the table in a single transaction. If the change log can't
+// be written, none of the run is reported as written.
+func (d *Destination) loggedWrite(ctx context.Context, t *target, tableName string, operation opencdc.Operation, payloads []*opencdc.Data, entries []map[string]interface{}, rs []*opencdc.Record) ([]bool, error) {
+	var landed []bool
+	var err error
+	if d.config.ChangeLog == changeLogOnly {
+		landed = make([]bool, len(payloads))
+		for i := range landed {
+			landed[i] = true
+		}
+	} else {
+		landed, err = d.writeRun(ctx, t, tableName, operation, payloads, rs)
+	}
+
+	var logged []map[string]interface{}
+	for i, ok := range landed {
+		if ok {
+			logged = append(logged, entries[i])
+		}
+	}
+	if len(logged) == 0 {
+		return landed, err
+	}
+
+	var query strings.Builder
+	vars := make(map[string]interface{})
+	query.WriteString("BEGIN TRANSACTION;\n")
+	changeLogStatements(&query, vars, tableName, logged)
+	query.WriteString("COMMIT TRANSACTION;")
+
+	results, queryErr := surrealdb.Query[interface{}](t.db, query.String(), vars)
+	if queryErr == nil {
+		for _, result := range *results {
+			if result.Status != "OK" {
+				queryErr = statementError{result.Result}
+				break
+			}
+		}
+	}
+	if queryErr != nil {
+		sdk.Logger(ctx).Error().Msg("Failed to log changes: " + queryErr.Error())
+		return make([]bool, len(payloads)), fmt.Errorf("failed to log changes to %s: %w", changeLogTable(tableName), queryErr)
+	}
+	return landed, err
+}
@@ -0,0 +1,59 @@
+package destination
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/conduitio/conduit-commons/opencdc"
+	"github.com/matryer/is"
+	"github.com/surrealdb/surrealdb.go/pkg/models"
+)
+
+func TestChangeLogEntries(t *testing.T) {
+	is := is.New(t)
+	d := &Destination{config: Config{ChangeLog: changeLogAppend}}
+
+	recs := []opencdc.Record{
+		{
+			Operation: opencdc.OperationUpdate,
+			Position:  opencdc.Position("1"),
+			Metadata:  opencdc.Metadata{"opencdc.collection": "wp_posts"},
+			Payload: opencdc.Change{
+				Before: opencdc.RawData(`{"id": 5, "title": "old"}`),
+				After:  opencdc.StructuredData{"id": int64(5), "title": "new"},
+			},
+		},
+		{
+			Operation: opencdc.OperationDelete,
+			Position:  opencdc.Position("2"),
+			Payload:   opencdc.Change{After: opencdc.StructuredData{"id": int64(5)}},
+		},
+	}
+	entries, err := d.changeLogEntries("wp_posts", recs, []int{0, 1})
+	is.NoErr(err)
+	is.Equal(len(entries), 2)
+
+	is.Equal(entries[0]["record"], models.NewRecordID("wp_posts", int64(5)))
+	is.Equal(entries[0]["operation"], "update")
+	is.Equal(entries[0]["before"], opencdc.StructuredData{"id": int64(5), "title": "old"})
+	is.Equal(entries[0]["after"], opencdc.StructuredData{"id": int64(5), "title": "new"})
+	is.Equal(entries[0]["position"], "1")
+	is.Equal(entries[0]["metadata"], map[string]string{"opencdc.collection": "wp_posts"})
+
+	is.Equal(entries[1]["operation"], "delete")
+	_, hasAfter := entries[1]["after"]
+	is.True(!hasAfter)
+
+	var query strings.Builder
+	vars := make(map[string]interface{})
+	changeLogStatements(&query, vars, "wp_posts", entries)
+	is.Equal(vars["changes_tb"], "wp_posts_changes")
+	is.Equal(strings.Count(query.String(), "CREATE type::thing($changes_tb, rand::ulid())"), 2)
+	is.Equal(vars["change1"], entries[1])
+}
+
+func TestChangeLogCoalesce(t *testing.T) {
+	is := is.New(t)
+	is.True(Config{ChangeLog: changeLogOnly, Coalesce: true}.Validate() != nil)
+	is.NoErr(Config{ChangeLog: changeLogOff, Coalesce: true}.Validate())
+}
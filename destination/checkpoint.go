@@ -0,0 +1,253 @@
+package destination
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/conduitio/conduit-commons/opencdc"
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/surrealdb/surrealdb.go"
+)
+
+// checkpoint is the position of the last record of a source that was written to
+// a table, as stored in the checkpoint table.
+type checkpoint struct {
+	Source     string `json:"source"`
+	Collection string `json:"collection"`
+	Position   []byte `json:"position"`
+}
+
+// checkpointKey identifies the checkpoint of the table a record is written to.
+func checkpointKey(source, table string) string {
+	return source + "/" + table
+}
+
+// loadCheckpoints reads all checkpoints stored in a target, so that records
+// that were written before a crash can be recognized when they are replayed.
+func (d *Destination) loadCheckpoints(t *target) error {
+	results, err := surrealdb.Query[[]checkpoint](t.db, "SELECT source, collection, position FROM type::table($tb)", map[string]interface{}{
+		"tb": d.config.CheckpointTable,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to load checkpoints: %w", err)
+	}
+
+	t.checkpoints = make(map[string]opencdc.Position)
+	for _, result := range *results {
+		if result.Status != "OK" {
+			return fmt.Errorf("failed to load checkpoints: %v", result.Result)
+		}
+		for _, cp := range result.Result {
+			t.checkpoints[checkpointKey(cp.Source, cp.Collection)] = cp.Position
+		}
+	}
+	return nil
+}
+
+// skipCheckpointed drops the records of a single table, given by their
+// positions in recs, that are at or before the stored checkpoint of their
+// source. Positions are opaque, so a record is only known to be at or before
+// the checkpoint if the checkpointed record itself is part of the batch. This
+// covers the usual replay after a crash, where the last batch is written but
+// never acknowledged. It returns the positions that still need to be written
+// and the skipped ones.
+func (d *Destination) skipCheckpointed(t *target, recs []opencdc.Record, table string, positions []int) ([]int, []int) {
+	t.checkpointsMu.Lock()
+	defer t.checkpointsMu.Unlock()
+
+	// the index of the checkpointed record of every source, if it's in the batch
+	checkpointed := make(map[string]int)
+	for i := len(positions) - 1; i >= 0; i-- {
+		rec := &recs[positions[i]]
+		source := rec.Metadata[opencdc.MetadataConduitSourceConnectorID]
+		if _, ok := checkpointed[source]; ok {
+			continue
+		}
+		if stored, ok := t.checkpoints[checkpointKey(source, table)]; ok && bytes.Equal(stored, rec.Position) {
+			checkpointed[source] = i
+		}
+	}
+	if len(checkpointed) == 0 {
+		return positions, nil
+	}
+
+	var kept, skipped []int
+	for i, pos := range positions {
+		last, ok := checkpointed[recs[pos].Metadata[opencdc.MetadataConduitSourceConnectorID]]
+		if ok && i <= last {
+			skipped = append(skipped, pos)
+		} else {
+			kept = append(kept, pos)
+		}
+	}
+	return kept, skipped
+}
+
+// checkpointedWrite writes a run of payloads of a single table, their change
+// log entries if there are any, and the positions of rs, the records of the
+// payloads, in a single transaction. Either all of the run lands together with
+// its checkpoints or none of it does.
+//
+// Inserts that SurrealDB rejects are bisected like in insertSplitting, with
+// every range written in a transaction of its own. Ranges after a record that
+// failed don't move the checkpoints, so that the failed record isn't skipped
+// when it is replayed.
+func (d *Destination) checkpointedWrite(ctx context.Context, t *target, tableName string, operation opencdc.Operation, payloads []*opencdc.Data, entries []map[string]interface{}, rs []*opencdc.Record) ([]bool, error) {
+	if d.splitsInserts(tableName, operation) {
+		// the payloads up to done were written
+		done := 0
+		return splitWrites(tableName, payloads, func(lo, hi int) error {
+			var checkpointed []*opencdc.Record
+			if lo == done {
+				checkpointed = rs[lo:hi]
+			}
+			var rangeEntries []map[string]interface{}
+			if entries != nil {
+				rangeEntries = entries[lo:hi]
+			}
+			if err := d.checkpointedTransaction(ctx, t, tableName, operation, payloads[lo:hi], rangeEntries, checkpointed); err != nil {
+				return err
+			}
+			if lo == done {
+				done = hi
+			}
+			return nil
+		})
+	}
+
+	landed := make([]bool, len(payloads))
+	if err := d.checkpointedTransaction(ctx, t, tableName, operation, payloads, entries, rs); err != nil {
+		return landed, err
+	}
+	for i := range landed {
+		landed[i] = true
+	}
+	return landed, nil
+}
+
+// splitsInserts reports whether rejected runs of a table with the given
+// operation are bisected to isolate the records that fail. Only plain inserts
+// are, as the other statements are applied one by one.
+func (d *Destination) splitsInserts(tableName string, operation opencdc.Operation) bool {
+	isInsert := operation == opencdc.OperationSnapshot || operation == opencdc.OperationCreate
+	return isInsert && d.tableConfig(tableName).Meta == nil && d.config.VersionField == "" && d.config.ChangeLog != changeLogOnly
+}
+
+// checkpointedTransaction writes payloads of a single table, their change log
+// entries and the checkpoints of the sources of rs in a single transaction.
+// Each source is checkpointed at the position of its last record in rs.
+func (d *Destination) checkpointedTransaction(ctx context.Context, t *target, tableName string, operation opencdc.Operation, payloads []*opencdc.Data, entries []map[string]interface{}, rs []*opencdc.Record) error {
+	var query strings.Builder
+	vars := map[string]interface{}{
+		"tb":            tableName,
+		"checkpoint_tb": d.config.CheckpointTable,
+	}
+	query.WriteString("BEGIN TRANSACTION;\n")
+	var err error
+	switch mc := d.tableConfig(tableName).Meta; {
+	case d.config.ChangeLog == changeLogOnly:
+		// only the change log entries are written
+	case mc != nil:
+		_, err = metaStatements(&query, vars, mc, operation, payloads, metaMovedFrom(mc, operation, rs))
+	case d.config.VersionField != "":
+		err = d.versionedStatements(&query, vars, tableName, operation, payloads)
+	default:
+		err = d.plainStatements(&query, vars, tableName, operation, payloads)
+	}
+	if err != nil {
+		return err
+	}
+	if ec := d.tableConfig(tableName).Embed; ec != nil && d.config.ChangeLog != changeLogOnly {
+		children := make([]opencdc.StructuredData, len(payloads))
+		for i, payload := range payloads {
+			children[i], _ = (*payload).(opencdc.StructuredData)
+		}
+		if err := embedStatements(&query, vars, tableName, ec, d.config.VersionField, operation, children, movedFrom(ec, operation, rs)); err != nil {
+			return err
+		}
+	}
+	if entries != nil {
+		changeLogStatements(&query, vars, tableName, entries)
+	}
+	checkpoints := checkpointStatements(&query, vars, rs)
+	query.WriteString("COMMIT TRANSACTION;")
+
+	results, err := surrealdb.Query[interface{}](t.db, query.String(), vars)
+	if err != nil {
+		sdk.Logger(ctx).Error().Msg("Failed to write checkpointed records: " + err.Error())
+		return fmt.Errorf("failed to write checkpointed records: %w", err)
+	}
+	for _, result := range *results {
+		if result.Status != "OK" {
+			return fmt.Errorf("failed to write checkpointed records: %w", statementError{result.Result})
+		}
+	}
+
+	t.checkpointsMu.Lock()
+	for source, position := range checkpoints {
+		t.checkpoints[checkpointKey(source, tableName)] = position
+	}
+	t.checkpointsMu.Unlock()
+	return nil
+}
+
+// checkpointStatements appends the statements that store the position of the
+// last record of every source in rs as its checkpoint of the table given by $tb
+// to query, and adds the variables they use to vars. It returns the positions
+// by source.
+func checkpointStatements(query *strings.Builder, vars map[string]interface{}, rs []*opencdc.Record) map[string]opencdc.Position {
+	checkpoints := make(map[string]opencdc.Position)
+	var sources []string
+	for _, r := range rs {
+		source := r.Metadata[opencdc.MetadataConduitSourceConnectorID]
+		if _, ok := checkpoints[source]; !ok {
+			sources = append(sources, source)
+		}
+		checkpoints[source] = r.Position
+	}
+	for i, source := range sources {
+		vars[fmt.Sprintf("source%d", i)] = source
+		vars[fmt.Sprintf("position%d", i)] = []byte(checkpoints[source])
+		fmt.Fprintf(query, "UPSERT type::thing($checkpoint_tb, [$source%[1]d, $tb]) SET source = $source%[1]d, collection = $tb, position = $position%[1]d, updated_at = time::now();\n", i)
+	}
+	return checkpoints
+}
+
+// plainStatements appends the statements that write payloads into tableName,
+// given by $tb, to query, and adds the variables they use to vars.
+func (d *Destination) plainStatements(query *strings.Builder, vars map[string]interface{}, tableName string, operation opencdc.Operation, payloads []*opencdc.Data) error {
+	data := make([]opencdc.StructuredData, len(payloads))
+	for i, payload := range payloads {
+		payloadMap, ok := (*payload).(opencdc.StructuredData)
+		if !ok {
+			return fmt.Errorf("unexpected type for payload: %T", *payload)
+		}
+		data[i] = payloadMap
+	}
+
+	switch operation {
+	case opencdc.OperationSnapshot, opencdc.OperationCreate:
+		if len(d.foldedFields(tableName)) > 0 {
+			// meta rows may have created the records already
+			return d.upsertStatements(query, vars, tableName, payloads)
+		}
+		vars["data"] = data
+		query.WriteString("INSERT INTO type::table($tb) $data;\n")
+	case opencdc.OperationUpdate:
+		for i, payloadMap := range data {
+			vars[fmt.Sprintf("id%d", i)] = payloadMap["id"]
+			vars[fmt.Sprintf("data%d", i)] = withoutID(payloadMap)
+			fmt.Fprintf(query, "UPDATE type::thing($tb, $id%d) CONTENT %s;\n", i, d.content(tableName, fmt.Sprintf("$data%d", i)))
+		}
+	case opencdc.OperationDelete:
+		for i, payloadMap := range data {
+			vars[fmt.Sprintf("id%d", i)] = payloadMap["id"]
+			fmt.Fprintf(query, "DELETE type::thing($tb, $id%d);\n", i)
+		}
+	default:
+		return fmt.Errorf("invalid operation %q", operation)
+	}
+	return nil
+}
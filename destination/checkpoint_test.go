@@ -0,0 +1,116 @@
+package destination
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/conduitio/conduit-commons/opencdc"
+	"github.com/matryer/is"
+)
+
+func TestSkipCheckpointed(t *testing.T) {
+	is := is.New(t)
+	d := &Destination{}
+
+	rec := func(source, position string) opencdc.Record {
+		return opencdc.Record{
+			Position: opencdc.Position(position),
+			Metadata: opencdc.Metadata{opencdc.MetadataConduitSourceConnectorID: source},
+		}
+	}
+	recs := []opencdc.Record{
+		rec("mysql", "1"),
+		rec("postgres", "a"),
+		rec("mysql", "2"),
+		rec("postgres", "b"),
+		rec("mysql", "3"),
+	}
+	tg := &target{checkpoints: map[string]opencdc.Position{
+		checkpointKey("mysql", "wp_posts"): opencdc.Position("2"),
+	}}
+
+	// only the records of the checkpointed source are skipped
+	kept, skipped := d.skipCheckpointed(tg, recs, "wp_posts", []int{0, 1, 2, 3, 4})
+	is.Equal(kept, []int{1, 3, 4})
+	is.Equal(skipped, []int{0, 2})
+
+	// checkpoints of other tables don't apply
+	kept, skipped = d.skipCheckpointed(tg, recs, "wp_users", []int{0, 1, 2, 3, 4})
+	is.Equal(kept, []int{0, 1, 2, 3, 4})
+	is.Equal(len(skipped), 0)
+
+	tg.checkpoints[checkpointKey("postgres", "wp_posts")] = opencdc.Position("b")
+	kept, skipped = d.skipCheckpointed(tg, recs, "wp_posts", []int{0, 1, 2, 3, 4})
+	is.Equal(kept, []int{4})
+	is.Equal(skipped, []int{0, 1, 2, 3})
+}
+
+func TestPlainStatements(t *testing.T) {
+	is := is.New(t)
+	d := &Destination{tables: map[string]TableConfig{
+		"wp_postmeta": {Meta: &MetaConfig{Parent: "wp_posts", Field: "meta"}},
+	}}
+
+	data := func(m opencdc.StructuredData) *opencdc.Data {
+		var d opencdc.Data = m
+		return &d
+	}
+	payloads := []*opencdc.Data{
+		data(opencdc.StructuredData{"id": 1, "title": "a"}),
+		data(opencdc.StructuredData{"id": 2, "title": "b"}),
+	}
+
+	var query strings.Builder
+	vars := make(map[string]interface{})
+	is.NoErr(d.plainStatements(&query, vars, "wp_users", opencdc.OperationSnapshot, payloads))
+	is.Equal(query.String(), "INSERT INTO type::table($tb) $data;\n")
+	is.Equal(vars["data"], []opencdc.StructuredData{{"id": 1, "title": "a"}, {"id": 2, "title": "b"}})
+
+	// posts may have been created by their meta rows already
+	query.Reset()
+	vars = make(map[string]interface{})
+	is.NoErr(d.plainStatements(&query, vars, "wp_posts", opencdc.OperationSnapshot, payloads[:1]))
+	is.Equal(query.String(), "UPSERT type::thing($tb, $id0) CONTENT object::from_entries(array::concat(object::entries($data0), object::entries({ meta: meta })));\n")
+	is.Equal(vars, map[string]interface{}{"id0": 1, "data0": map[string]interface{}{"title": "a"}})
+
+	// updates keep the meta rows folded into the posts
+	query.Reset()
+	vars = make(map[string]interface{})
+	is.NoErr(d.plainStatements(&query, vars, "wp_posts", opencdc.OperationUpdate, payloads[:1]))
+	is.Equal(query.String(), "UPDATE type::thing($tb, $id0) CONTENT object::from_entries(array::concat(object::entries($data0), object::entries({ meta: meta })));\n")
+	is.Equal(vars, map[string]interface{}{"id0": 1, "data0": map[string]interface{}{"title": "a"}})
+
+	query.Reset()
+	vars = make(map[string]interface{})
+	is.NoErr(d.plainStatements(&query, vars, "wp_posts", opencdc.OperationDelete, payloads))
+	is.Equal(query.String(), "DELETE type::thing($tb, $id0);\nDELETE type::thing($tb, $id1);\n")
+	is.Equal(vars, map[string]interface{}{"id0": 1, "id1": 2})
+
+	is.True(d.plainStatements(&query, vars, "wp_posts", opencdc.Operation(0), payloads) != nil)
+}
+
+func TestCheckpointStatements(t *testing.T) {
+	is := is.New(t)
+
+	rec := func(source, position string) *opencdc.Record {
+		return &opencdc.Record{
+			Position: opencdc.Position(position),
+			Metadata: opencdc.Metadata{opencdc.MetadataConduitSourceConnectorID: source},
+		}
+	}
+
+	var query strings.Builder
+	vars := make(map[string]interface{})
+	checkpoints := checkpointStatements(&query, vars, []*opencdc.Record{
+		rec("mysql", "1"), rec("postgres", "a"), rec("mysql", "2"),
+	})
+	is.Equal(checkpoints, map[string]opencdc.Position{"mysql": opencdc.Position("2"), "postgres": opencdc.Position("a")})
+	is.Equal(strings.Count(query.String(), "UPSERT type::thing($checkpoint_tb"), 2)
+	is.Equal(vars["source0"], "mysql")
+	is.Equal(vars["position0"], []byte("2"))
+	is.Equal(vars["source1"], "postgres")
+
+	query.Reset()
+	is.Equal(len(checkpointStatements(&query, vars, nil)), 0)
+	is.Equal(query.String(), "")
+}
@@ -0,0 +1,100 @@
+package destination
+
+import (
+	"fmt"
+
+	"github.com/conduitio/conduit-commons/opencdc"
+)
+
+// noOp marks a record that was coalesced away entirely, e.g. a create that is
+// deleted again in the same batch. Such records count as written.
+const noOp = -1
+
+// coalesce reduces the records of a single table, given by their positions in
+// recs, to the net effect per record id. It returns the positions that still
+// need to be written and, for every position that was folded into another one,
+// the position it was folded into (or noOp).
+//
+// The rules are last-write-wins, applied in the order the records arrived:
+//   - a create or update followed by updates is written once, with the last payload
+//   - a create followed by a delete is dropped entirely
+//   - an update followed by a delete becomes the delete
+//   - a delete followed by a create or update is kept as is, since the record
+//     has to be removed before it can be written again
+//
+// Records without an id are never coalesced.
+func coalesce(recs []opencdc.Record, positions []int) ([]int, map[int]int) {
+	absorbed := make(map[int]int)
+	current := make(map[string]int)
+
+	for _, pos := range positions {
+		id, ok := payloadID(recs[pos].Payload.After)
+		if !ok {
+			continue
+		}
+		prev, ok := current[id]
+		if !ok {
+			current[id] = pos
+			continue
+		}
+
+		prevOp := recs[prev].Operation
+		switch op := recs[pos].Operation; {
+		case op == opencdc.OperationDelete && prevOp == opencdc.OperationDelete:
+			absorbed[pos] = prev
+		case op == opencdc.OperationDelete && prevOp == opencdc.OperationUpdate:
+			absorbed[prev] = pos
+			current[id] = pos
+		case op == opencdc.OperationDelete:
+			absorbed[prev] = noOp
+			absorbed[pos] = noOp
+			delete(current, id)
+		case prevOp == opencdc.OperationDelete:
+			current[id] = pos
+		default:
+			recs[prev].Payload.After = recs[pos].Payload.After
+			absorbed[pos] = prev
+		}
+	}
+
+	kept := positions[:0:0]
+	for _, pos := range positions {
+		if _, ok := absorbed[pos]; !ok {
+			kept = append(kept, pos)
+		}
+	}
+	return kept, absorbed
+}
+
+// resolveCoalesced marks every position that was folded into another one as
+// written if the position it ended up in was written.
+func resolveCoalesced(absorbed map[int]int, written []bool) {
+	for pos := range absorbed {
+		target := pos
+		for {
+			next, ok := absorbed[target]
+			if !ok {
+				break
+			}
+			target = next
+			if target == noOp {
+				break
+			}
+		}
+		written[pos] = target == noOp || written[target]
+	}
+}
+
+// payloadID returns the "id" field of a structured payload as a string that
+// can be used to compare record ids.
+func payloadID(payload opencdc.Data) (string, bool) {
+	payloadMap, ok := payload.(opencdc.StructuredData)
+	if !ok {
+		return "", false
+	}
+	id, ok := payloadMap["id"]
+	if !ok || id == nil {
+		return "", false
+	}
+	return fmt.Sprintf("%T:%v", id, id), true
+}
@@ -0,0 +1,40 @@
+package destination
+
+import (
+	"testing"
+
+	"github.com/conduitio/conduit-commons/opencdc"
+	"github.com/matryer/is"
+)
+
+func TestCoalesce(t *testing.T) {
+	is := is.New(t)
+
+	rec := func(op opencdc.Operation, id int, title string) opencdc.Record {
+		return opencdc.Record{
+			Operation: op,
+			Payload: opencdc.Change{
+				After: opencdc.StructuredData{"id": id, "title": title},
+			},
+		}
+	}
+	recs := []opencdc.Record{
+		rec(opencdc.OperationCreate, 1, "a"),
+		rec(opencdc.OperationUpdate, 1, "b"),
+		rec(opencdc.OperationCreate, 2, "a"),
+		rec(opencdc.OperationUpdate, 3, "a"),
+		rec(opencdc.OperationDelete, 2, ""),
+		rec(opencdc.OperationDelete, 3, ""),
+		rec(opencdc.OperationUpdate, 1, "c"),
+	}
+
+	kept, absorbed := coalesce(recs, []int{0, 1, 2, 3, 4, 5, 6})
+	is.Equal(kept, []int{0, 5})
+	is.Equal(recs[0].Payload.After.(opencdc.StructuredData)["title"], "c")
+	is.Equal(absorbed, map[int]int{1: 0, 2: noOp, 3: 5, 4: noOp, 6: 0})
+
+	written := make([]bool, len(recs))
+	written[0] = true
+	resolveCoalesced(absorbed, written)
+	is.Equal(written, []bool{true, true, true, false, true, false, true})
+}
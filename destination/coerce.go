@@ -0,0 +1,346 @@
+package destination
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/conduitio/conduit-commons/opencdc"
+	"github.com/fxamacker/cbor/v2"
+	"github.com/gofrs/uuid"
+	"github.com/surrealdb/surrealdb.go/pkg/models"
+)
+
+// datetimeLayouts are the layouts datetime strings are parsed with, in order.
+var datetimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02",
+}
+
+// durationPattern matches durations in SurrealDB syntax, e.g. "1w2d12h".
+var durationPattern = regexp.MustCompile(`^(\d+(y|w|d|h|m|s|ms|µs|us|ns))+$`)
+
+// coerceTypes converts the payload values of r into native SurrealDB values,
+// so they can be queried with SurrealDB's functions instead of being stored as
+// strings and floats. The target types come from the payload schema attached
+// to r if CoerceTypes is enabled, and from the types configured for the table.
+func (d *Destination) coerceTypes(ctx context.Context, r *opencdc.Record) error {
+	tableName, _ := d.getTableName(*r)
+	configured := d.tableConfig(tableName).Types
+	if !d.config.CoerceTypes && len(configured) == 0 {
+		return nil
+	}
+
+	types := make(map[string]schemaField)
+	if d.config.CoerceTypes {
+		schemaTypes, err := d.payloadSchemaTypes(ctx, r)
+		if err != nil {
+			return err
+		}
+		for field, typ := range d.renameTypes(tableName, schemaTypes) {
+			types[field] = typ
+		}
+	}
+	for field, typ := range configured {
+		types[field] = schemaField{Name: field, Type: typ}
+	}
+
+	afterMap, ok := r.Payload.After.(opencdc.StructuredData)
+	if !ok {
+		return fmt.Errorf("unexpected type for r.Payload.After: %T", r.Payload.After)
+	}
+	for field, typ := range types {
+		value, ok := afterMap[field]
+		if !ok {
+			continue
+		}
+		coerced, err := coerceValue(value, typ.Type, typ.Unit)
+		if err != nil {
+			return fmt.Errorf("failed to convert field %s to %s: %w", field, typ.Type, err)
+		}
+		afterMap[field] = coerced
+	}
+	return nil
+}
+
+// payloadSchemaTypes returns the fields in the payload schema attached to r by
+// name, or nil if there is none.
+func (d *Destination) payloadSchemaTypes(ctx context.Context, r *opencdc.Record) (map[string]schemaField, error) {
+	subject, version, err := payloadSchemaRef(r)
+	if err != nil || subject == "" {
+		return nil, err
+	}
+
+	key := fmt.Sprintf("%s:%d", subject, version)
+	if types, ok := d.schemaTypes[key]; ok {
+		return types, nil
+	}
+	fields, err := fetchSchemaFields(ctx, subject, version)
+	if err != nil {
+		return nil, err
+	}
+	fields = d.normalizeSchemaFieldNames(fields)
+	types := make(map[string]schemaField, len(fields))
+	for _, field := range fields {
+		types[field.Name] = field
+	}
+	d.schemaTypes[key] = types
+	return types, nil
+}
+
+// coerceValue converts value into the native value for the SurrealDB type typ.
+// Types that need no conversion are returned as is. unit is the unit numbers
+// are counted in since the epoch for datetimes, milliseconds if it's zero.
+func coerceValue(value interface{}, typ string, unit time.Duration) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	// nullable fields accept NULL as well, which needs no conversion
+	_, kinds := splitType(typ)
+	kinds = slices.DeleteFunc(kinds, func(kind string) bool { return kind == "null" })
+	if len(kinds) == 1 {
+		typ = kinds[0]
+	}
+
+	if strings.HasPrefix(typ, "array<") && strings.HasSuffix(typ, ">") {
+		items, ok := value.([]interface{})
+		if !ok {
+			return value, nil
+		}
+		itemType := strings.TrimSuffix(strings.TrimPrefix(typ, "array<"), ">")
+		coerced := make([]interface{}, len(items))
+		for i, item := range items {
+			c, err := coerceValue(item, itemType, unit)
+			if err != nil {
+				return nil, fmt.Errorf("item %d: %w", i, err)
+			}
+			coerced[i] = c
+		}
+		return coerced, nil
+	}
+
+	switch typ {
+	case "datetime":
+		return coerceDatetime(value, unit)
+	case "decimal":
+		return coerceDecimal(value)
+	case "duration":
+		return coerceDuration(value)
+	case "uuid":
+		return coerceUUID(value)
+	case "bytes":
+		return coerceBytes(value)
+	default:
+		return value, nil
+	}
+}
+
+// datetime is a time that is encoded the way SurrealDB encodes datetimes
+// itself, as seconds and nanoseconds since the epoch. surrealdb.go v0.3.0
+// encodes time.Time with the standard CBOR datetime tag at second precision,
+// and models.CustomDateTime drops everything but the fraction of a second.
+type datetime time.Time
+
+// MarshalCBOR implements cbor.Marshaler.
+func (dt datetime) MarshalCBOR() ([]byte, error) {
+	t := time.Time(dt)
+	return cbor.Marshal(cbor.Tag{
+		Number:  models.TagCustomDatetime,
+		Content: [2]int64{t.Unix(), int64(t.Nanosecond())},
+	})
+}
+
+// coerceDatetime accepts times, strings in one of datetimeLayouts and numbers
+// of units since the epoch, like Avro's date, timestamp-millis and
+// timestamp-micros. Numbers are milliseconds if unit is zero.
+func coerceDatetime(value interface{}, unit time.Duration) (interface{}, error) {
+	switch v := value.(type) {
+	case datetime:
+		return v, nil
+	case time.Time:
+		return datetime(v), nil
+	case models.CustomDateTime:
+		return datetime(v.Time), nil
+	case string:
+		for _, layout := range datetimeLayouts {
+			if t, err := time.Parse(layout, v); err == nil {
+				return datetime(t), nil
+			}
+		}
+		return nil, fmt.Errorf("invalid datetime %q", v)
+	default:
+		n, err := toInt(value)
+		if err != nil {
+			return nil, err
+		}
+		return datetime(epochTime(n, unit)), nil
+	}
+}
+
+// epochTime returns the time n units after the epoch, or n milliseconds if unit
+// is zero.
+func epochTime(n int64, unit time.Duration) time.Time {
+	if unit == 0 {
+		unit = time.Millisecond
+	}
+	if unit >= time.Second {
+		return time.Unix(n*int64(unit/time.Second), 0).UTC()
+	}
+	perSecond := int64(time.Second / unit)
+	return time.Unix(n/perSecond, n%perSecond*int64(unit)).UTC()
+}
+
+// coerceDecimal returns value as an exact SurrealDB decimal.
+func coerceDecimal(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case models.DecimalString:
+		return v, nil
+	case string:
+		if _, ok := new(big.Rat).SetString(v); !ok {
+			return nil, fmt.Errorf("invalid decimal %q", v)
+		}
+		return models.DecimalString(v), nil
+	case json.Number:
+		return models.DecimalString(v.String()), nil
+	case *big.Rat:
+		return models.DecimalString(ratString(v)), nil
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, fmt.Errorf("invalid decimal %v", v)
+		}
+		return models.DecimalString(strconv.FormatFloat(v, 'f', -1, 64)), nil
+	default:
+		if _, err := toFloat(value); err != nil {
+			return nil, err
+		}
+		return models.DecimalString(fmt.Sprint(v)), nil
+	}
+}
+
+// ratString formats r as a decimal number, exactly if its denominator is a
+// power of ten, which is the case for every decimal decoded from Avro.
+func ratString(r *big.Rat) string {
+	const maxScale = 38
+	denom := new(big.Int).Set(r.Denom())
+	ten := big.NewInt(10)
+	for scale := 0; scale <= maxScale; scale++ {
+		if new(big.Int).Mod(new(big.Int).Exp(ten, big.NewInt(int64(scale)), nil), denom).Sign() == 0 {
+			return r.FloatString(scale)
+		}
+	}
+	return r.FloatString(maxScale)
+}
+
+// coerceDuration accepts durations and strings in either Go or SurrealDB
+// duration syntax.
+func coerceDuration(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case time.Duration:
+		return models.CustomDuration{Duration: v}, nil
+	case models.CustomDuration:
+		return v, nil
+	case string:
+		if d, err := time.ParseDuration(v); err == nil {
+			return models.CustomDuration{Duration: d}, nil
+		}
+		if !durationPattern.MatchString(v) {
+			return nil, fmt.Errorf("invalid duration %q", v)
+		}
+		ns, err := models.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid duration %q: %w", v, err)
+		}
+		return models.CustomDuration{Duration: time.Duration(ns)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported duration value of type %T", value)
+	}
+}
+
+// coerceUUID accepts UUIDs as strings or 16 bytes.
+func coerceUUID(value interface{}) (interface{}, error) {
+	var u uuid.UUID
+	var err error
+	switch v := value.(type) {
+	case models.UUIDString:
+		return v, nil
+	case string:
+		u, err = uuid.FromString(v)
+	case []byte:
+		u, err = uuid.FromBytes(v)
+	default:
+		return nil, fmt.Errorf("unsupported uuid value of type %T", value)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid uuid: %w", err)
+	}
+	return models.UUIDString(u.String()), nil
+}
+
+// coerceBytes accepts bytes and base64 encoded strings.
+func coerceBytes(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case []byte:
+		return v, nil
+	case string:
+		b, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64: %w", err)
+		}
+		return b, nil
+	default:
+		return nil, fmt.Errorf("unsupported bytes value of type %T", value)
+	}
+}
+
+// toInt converts a numeric value to an int64, rounding fractions.
+func toInt(value interface{}) (int64, error) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n, nil
+		}
+	}
+	f, err := toFloat(value)
+	if err != nil {
+		return 0, err
+	}
+	return int64(math.Round(f)), nil
+}
+
+// toFloat converts a numeric value to a float64.
+func toFloat(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case float32:
+		return float64(v), nil
+	case int:
+		return float64(v), nil
+	case int32:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case json.Number:
+		return v.Float64()
+	case models.DecimalString:
+		return strconv.ParseFloat(string(v), 64)
+	default:
+		return 0, fmt.Errorf("unsupported numeric value of type %T", value)
+	}
+}
@@ -0,0 +1,57 @@
+package destination
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/matryer/is"
+	"github.com/surrealdb/surrealdb.go/pkg/models"
+)
+
+func TestCoerceValue(t *testing.T) {
+	is := is.New(t)
+
+	testCases := []struct {
+		value interface{}
+		typ   string
+		unit  time.Duration
+		want  interface{}
+	}{
+		{"2024-03-01 10:15:00.123456789", "datetime", 0, datetime(time.Date(2024, 3, 1, 10, 15, 0, 123456789, time.UTC))},
+		{float64(1709288100000), "option<datetime>", 0, datetime(time.Date(2024, 3, 1, 10, 15, 0, 0, time.UTC))},
+		{int64(1709288100123456), "datetime", time.Microsecond, datetime(time.Date(2024, 3, 1, 10, 15, 0, 123456000, time.UTC))},
+		{int32(19783), "datetime", 24 * time.Hour, datetime(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))},
+		{"2024-03-01", "option<datetime | null>", 0, datetime(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))},
+		{12.5, "decimal", 0, models.DecimalString("12.5")},
+		{big.NewRat(1234, 100), "decimal", 0, models.DecimalString("12.34")},
+		{"1h30m", "duration", 0, models.CustomDuration{Duration: 90 * time.Minute}},
+		{"1d", "duration", 0, models.CustomDuration{Duration: 24 * time.Hour}},
+		{"F47AC10B-58CC-4372-A567-0E02B2C3D479", "uuid", 0, models.UUIDString("f47ac10b-58cc-4372-a567-0e02b2c3d479")},
+		{"aGk=", "bytes", 0, []byte("hi")},
+		{[]interface{}{"aGk="}, "array<bytes>", 0, []interface{}{[]byte("hi")}},
+		{"untouched", "string", 0, "untouched"},
+		{nil, "datetime", 0, nil},
+	}
+	for _, tc := range testCases {
+		got, err := coerceValue(tc.value, tc.typ, tc.unit)
+		is.NoErr(err)
+		is.Equal(got, tc.want)
+	}
+
+	_, err := coerceValue("yesterday", "datetime", 0)
+	is.True(err != nil)
+}
+
+func TestDatetimeMarshalCBOR(t *testing.T) {
+	is := is.New(t)
+
+	b, err := cbor.Marshal(datetime(time.Date(2024, 3, 1, 10, 15, 0, 123456789, time.UTC)))
+	is.NoErr(err)
+
+	var tag cbor.Tag
+	is.NoErr(cbor.Unmarshal(b, &tag))
+	is.Equal(tag.Number, uint64(models.TagCustomDatetime))
+	is.Equal(tag.Content, []interface{}{uint64(1709288100), uint64(123456789)})
+}
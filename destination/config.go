@@ -4,6 +4,8 @@ package destination
 // destination. If you don't need shared parameters you can entirely remove this
 // file.
 import (
+	"fmt"
+
 	"github.com/nickchomey/conduit-connector-surrealdb/common"
 )
 
@@ -12,4 +14,73 @@ type Config struct {
 	common.Config
 	// We will always set an "id" field. If the incoming primary key is not "id", then "id" will get its value. DeleteOldKey is a flag to delete the old key and value from payload. Set to false if you want to keep the old key and value in the payload.
 	DeleteOldKey bool `json:"delete_old_key" default:"false"`
+	// MaxConcurrency is the number of tables that are written to SurrealDB in parallel. Records of a single table are always written in the order they arrived.
+	MaxConcurrency int `json:"max_concurrency" default:"1" validate:"gt=0"`
+	// Coalesce reduces multiple changes to the same record within a batch to their net effect before writing them, e.g. several updates become the last one and a create followed by a delete is dropped.
+	Coalesce bool `json:"coalesce" default:"false"`
+	// VersionField is the name of the field holding the version or timestamp of a record. If set, creates, updates and deletes are only applied when the incoming version is newer than the one stored in SurrealDB, so replayed or out-of-order changes can't overwrite newer data.
+	VersionField string `json:"version_field"`
+	// VersionMetadata is the metadata key to take the version from, e.g. "opencdc.readAt". The value is stored in VersionField. If empty, the version is read from the payload.
+	VersionMetadata string `json:"version_metadata"`
+	// Checkpoints stores the position of the last record of each source written to each table in CheckpointTable, in the same transaction as the data. Records at or before a stored position are skipped when they are replayed after a crash.
+	Checkpoints bool `json:"checkpoints" default:"false"`
+	// CheckpointTable is the table the checkpoints are stored in, one record per source connector and table.
+	CheckpointTable string `json:"checkpoint_table" default:"_conduit_checkpoint"`
+	// DefineSchema defines a table as SCHEMAFULL, with typed fields taken from the payload schema in the schema registry, the first time a record of the table is written.
+	DefineSchema bool `json:"define_schema" default:"false"`
+	// SchemaEvolution decides what happens to breaking changes when the payload schema of a table changes: "apply" redefines the field with the new type, "log" keeps the old definition and logs a warning, "fail" stops the pipeline. New fields and widened types are always applied.
+	SchemaEvolution string `json:"schema_evolution" default:"log" validate:"inclusion=apply|log|fail"`
+	// CoerceTypes converts payload values into native SurrealDB datetimes, decimals, durations, uuids and bytes according to the payload schema. Types configured per table in the relations schema are always applied.
+	CoerceTypes bool `json:"coerce_types" default:"false"`
+	// DecimalNumbers stores JSON numbers with a fraction or exponent as SurrealDB decimals, so that e.g. money columns keep their exact value. Floats in structured payloads are stored as decimals as well, so a field gets the same type however the source encodes it. If it's disabled, they are stored as floats and JSON numbers that can't be stored exactly as float are rejected. Disabled by default, as enabling it changes the type of every fractional number already stored as float. Integers are always stored as int.
+	DecimalNumbers bool `json:"decimal_numbers" default:"false"`
+	// FieldNames decides how nested fields are written: "keep" writes them as they are, "expand" turns dotted keys like "address.city" into nested objects, "flatten" turns nested objects into underscored keys like "address_city".
+	FieldNames string `json:"field_names" default:"keep" validate:"inclusion=keep|expand|flatten"`
+	// SanitizeFieldNames replaces characters other than letters, digits and underscores in field names with underscores, puts an underscore in front of names starting with a digit and appends one to SurrealQL keywords like "value" or "select".
+	SanitizeFieldNames bool `json:"sanitize_field_names" default:"false"`
+	// NullValues decides how null values are written: "keep" writes NULL, "none" leaves the field out so it is NONE, "default" writes the default configured for the field in the relations schema, or leaves it out if there is none. Relation events only skip fields that are NONE. Can be overridden per table.
+	NullValues string `json:"null_values" default:"keep" validate:"inclusion=keep|none|default"`
+	// RawPayloads decides what happens to raw payloads that aren't a JSON object, like JSON arrays, CSV lines or binary blobs: "fail" rejects them, "bytes" and "string" store them in RawPayloadField as bytes or as a string. The id of the record is taken from its key.
+	RawPayloads string `json:"raw_payloads" default:"fail" validate:"inclusion=fail|bytes|string"`
+	// RawPayloadField is the field raw payloads are stored in.
+	RawPayloadField string `json:"raw_payload_field" default:"data"`
+	// MetadataField is the field the operation, position, read time and metadata of a record are written to, e.g. "_meta". Relation edges get a copy of the field of the record that created them. Nothing is written if empty.
+	MetadataField string `json:"metadata_field"`
+	// MetadataKeys are the metadata entries written to MetadataField. All entries are written if empty.
+	MetadataKeys []string `json:"metadata_keys"`
+	// ChangeLog appends every change as a new record to a "<table>_changes" table, holding the record, the operation, the payloads before and after the change, the position and the metadata: "off" logs nothing, "append" logs changes alongside writing them to the table, "only" logs them instead. Can't be used with Coalesce, which drops changes.
+	ChangeLog string `json:"change_log" default:"off" validate:"inclusion=off|append|only"`
+	// DeadLetters puts records that SurrealDB rejects, or that can't be processed, into DeadLetterTable together with the error, instead of stopping the pipeline. Fields the table excludes are left out of the stored record. Failures to reach SurrealDB, and transaction conflicts that persist after being retried, still stop it.
+	DeadLetters bool `json:"dead_letters" default:"false"`
+	// DeadLetterTable is the table records that failed are put into.
+	DeadLetterTable string `json:"dead_letter_table" default:"_conduit_dlq"`
+	// RouteNamespace is a Go template of the namespace a record is written to, executed with .Metadata, .Payload and .Table, e.g. `{{index .Metadata "tenant"}}`. Defaults to the configured namespace.
+	RouteNamespace string `json:"route_namespace"`
+	// RouteDatabase is a Go template of the database a record is written to, executed like RouteNamespace, e.g. `wp_{{.Payload.site}}`. Defaults to the configured database.
+	RouteDatabase string `json:"route_database"`
+	// CreateTargets defines the namespaces and databases records are written to if they don't exist yet.
+	CreateTargets bool `json:"create_targets" default:"false"`
+}
+
+// Validate checks the parameters that depend on each other or on a pattern.
+func (c Config) Validate() error {
+	if c.VersionMetadata != "" && c.VersionField == "" {
+		return fmt.Errorf("%q requires %q to be set", ConfigVersionMetadata, ConfigVersionField)
+	}
+	if c.Coalesce && (c.ChangeLog == changeLogAppend || c.ChangeLog == changeLogOnly) {
+		return fmt.Errorf("%q can't be used with %q", ConfigCoalesce, ConfigChangeLog)
+	}
+	if c.VersionField != "" && !targetNamePattern.MatchString(c.VersionField) {
+		return fmt.Errorf("invalid %q %q", ConfigVersionField, c.VersionField)
+	}
+	if c.DeadLetterTable != "" && !targetNamePattern.MatchString(c.DeadLetterTable) {
+		return fmt.Errorf("invalid %q %q", ConfigDeadLetterTable, c.DeadLetterTable)
+	}
+	if c.RawPayloadField != "" && !targetNamePattern.MatchString(c.RawPayloadField) {
+		return fmt.Errorf("invalid %q %q", ConfigRawPayloadField, c.RawPayloadField)
+	}
+	if c.MetadataField != "" && !targetNamePattern.MatchString(c.MetadataField) {
+		return fmt.Errorf("invalid %q %q", ConfigMetadataField, c.MetadataField)
+	}
+	return nil
 }
@@ -2,10 +2,16 @@ package destination
 
 import (
 	"bytes"
+	"cmp"
 	"context"
 	"encoding/json"
+	"errors"
+	"io"
+	"maps"
 	"os"
+	"slices"
 	"strings"
+	"sync"
 	"text/template"
 	"time"
 
@@ -18,6 +24,7 @@ import (
 	"github.com/conduitio/conduit-commons/opencdc"
 	sdk "github.com/conduitio/conduit-connector-sdk"
 	"github.com/surrealdb/surrealdb.go"
+	"github.com/surrealdb/surrealdb.go/pkg/connection"
 	"github.com/surrealdb/surrealdb.go/pkg/models"
 	"gopkg.in/yaml.v3"
 )
@@ -26,8 +33,28 @@ type Destination struct {
 	sdk.UnimplementedDestination
 
 	config Config
-	db     *surrealdb.DB
-	token  string
+
+	// target is the configured namespace and database, and targets holds the
+	// ones records were routed to with routeNamespace and routeDatabase, by
+	// targetKey.
+	target         *target
+	targets        map[string]*target
+	routeNamespace *template.Template
+	routeDatabase  *template.Template
+
+	// relations and analyzers are applied to every target.
+	relations []RelationEventConfig
+	analyzers []AnalyzerConfig
+
+	// schemaTypes caches the fields of payload schemas by name, by
+	// "subject:version".
+	schemaTypes map[string]map[string]schemaField
+	// tables holds the settings of individual tables from the relations schema.
+	tables map[string]TableConfig
+	// tableMapping maps collection names to table names, and mappedTables
+	// caches its results by collection.
+	tableMapping TableMapping
+	mappedTables map[string]mappedTable
 }
 
 type RelationEventConfig struct {
@@ -42,7 +69,10 @@ type RelationEventConfig struct {
 }
 
 type RelationSchema struct {
-	Relations []RelationEventConfig `yaml:"relations"`
+	Relations  []RelationEventConfig  `yaml:"relations"`
+	Analyzers  []AnalyzerConfig       `yaml:"analyzers"`
+	TableNames TableMapping           `yaml:"tableNames"`
+	Tables     map[string]TableConfig `yaml:"tables"`
 }
 
 func NewDestination() sdk.Destination {
@@ -72,6 +102,9 @@ func (d *Destination) Configure(ctx context.Context, cfg config.Config) error {
 	if err != nil {
 		return fmt.Errorf("invalid config: %w", err)
 	}
+	if err := d.config.Validate(); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
 	return nil
 }
 
@@ -80,46 +113,32 @@ func (d *Destination) Open(ctx context.Context) error {
 	// start writing records. If needed, the plugin should open connections in
 	// this function.
 
-	sdk.Logger(ctx).Info().Msg("Connecting to SurrealDB... on " + d.config.URL)
-
-	db, err := surrealdb.New(d.config.URL)
+	relationSchema, err := loadRelationSchema("relations_schema.yaml")
 	if err != nil {
-		sdk.Logger(ctx).Error().Msg("Failed to create SurrealDB client: " + err.Error())
-		return fmt.Errorf("failed to create SurrealDB client: %w", err)
+		panic(err)
 	}
-
-	if err = db.Use(d.config.Namespace, d.config.Database); err != nil {
-		sdk.Logger(ctx).Error().Msg("Failed to select namespace and database: " + err.Error())
-		return fmt.Errorf("failed to select namespace and database: %w", err)
+	d.relations = relationSchema.Relations
+	d.analyzers = relationSchema.Analyzers
+	d.tables = relationSchema.Tables
+	d.tableMapping = relationSchema.TableNames
+	if err := d.tableMapping.compile(); err != nil {
+		return err
 	}
-
-	authData := &surrealdb.Auth{
-		Username: d.config.Username,
-		Password: d.config.Password,
-		// Database:  d.config.Database,
-		// Namespace: d.config.Namespace,
-		// Scope:     d.config.Scope,
+	d.mappedTables = make(map[string]mappedTable)
+	if err := d.validateTables(); err != nil {
+		return err
 	}
-
-	token, err := db.SignIn(authData)
-	if err != nil {
-		sdk.Logger(ctx).Error().Msg("Failed to sign in to SurrealDB: " + err.Error())
-		return fmt.Errorf("failed to sign in to SurrealDB: %w", err)
+	if err := d.compileRoutes(); err != nil {
+		return err
 	}
 
-	relations, err := loadRelationSchema("relations_schema.yaml")
+	t, err := d.connect(ctx, d.config.Namespace, d.config.Database)
 	if err != nil {
-		panic(err)
-	}
-
-	for _, config := range relations {
-		if err := createRelationEvent(db, config); err != nil {
-			fmt.Printf("failed to create relation %s: %v\n", config.Name, err)
-		}
+		return err
 	}
-
-	d.token = token
-	d.db = db
+	d.target = t
+	d.targets = map[string]*target{targetKey(t.namespace, t.database): t}
+	d.schemaTypes = make(map[string]map[string]schemaField)
 	return nil
 }
 
@@ -134,87 +153,389 @@ func (d *Destination) Write(ctx context.Context, recs []opencdc.Record) (int, er
 
 	startTime := time.Now()
 
-	// Step 1: Group records by table and operation
-	groupedPayloads := make(map[string]map[opencdc.Operation][]*opencdc.Data)
+	// Step 1: Group records by target and table, keeping the order in which they
+	// arrived
+	var tables []tableGroup
+	groupedRecs := make(map[tableGroup][]int)
+	written := make([]bool, len(recs))
+
+	// Keep the records as they arrived, so that the ones that fail can be put
+	// into the dead letter table unchanged
+	var originals []opencdc.Record
+	if d.config.DeadLetters {
+		originals = make([]opencdc.Record, len(recs))
+		for i, rec := range recs {
+			originals[i] = rec.Clone()
+		}
+	}
 
 	//TODO: use goroutines here perhaps to process all records in parallel. Though this is generally quite fast. It is the actual CRUD on surrealdb that takes much longer.
 	for i := range recs {
 		//TODO: verify whether it might cause any problems here by using a pointer to the record. Does that affect something upstream if the same record is used in multiple connectors? Otherwise it seems like a better idea, since we could, in theory, have tens of thousands of records coming in at a time (default fetch size is 50000 PER TABLE in mysql connector, and this receives all tables in an interspersed batch)
 		rec := &recs[i]
-		tableName := rec.Metadata["opencdc.collection"]
-		if _, ok := groupedPayloads[tableName]; !ok {
-			groupedPayloads[tableName] = make(map[opencdc.Operation][]*opencdc.Data)
+		tableName, err := d.prepareRecord(ctx, rec)
+		if err != nil && d.config.DeadLetters {
+			// records that can't be processed would fail the same way again
+			if err := d.deadLetter(ctx, d.target, tableName, originals[i], err); err != nil {
+				return 0, err
+			}
+			written[i] = true
+			continue
 		}
-		// pass the record to processRec function to be prepared for insertion
-		err := d.processPayload(rec)
 		if err != nil {
-			return 0, fmt.Errorf("failed to process record: %w", err)
+			return 0, err
+		}
+		t, err := d.route(ctx, tableName, *rec)
+		if err != nil {
+			return 0, err
+		}
+		if d.config.DefineSchema {
+			if err := d.defineSchema(ctx, t, tableName, rec); err != nil {
+				return 0, err
+			}
 		}
 
-		groupedPayloads[tableName][rec.Operation] = append(groupedPayloads[tableName][rec.Operation], &rec.Payload.After)
+		group := tableGroup{target: t, table: tableName}
+		if _, ok := groupedRecs[group]; !ok {
+			tables = append(tables, group)
+		}
+		groupedRecs[group] = append(groupedRecs[group], i)
+	}
+
+	// Skip records that were written already before the pipeline was restarted
+	if d.config.Checkpoints {
+		for group, positions := range groupedRecs {
+			kept, skipped := d.skipCheckpointed(group.target, recs, group.table, positions)
+			groupedRecs[group] = kept
+			for _, pos := range skipped {
+				written[pos] = true
+			}
+			if len(skipped) > 0 {
+				sdk.Logger(ctx).Info().Msg(fmt.Sprintf("Skipped %d records of table %s that were written before", len(skipped), group.table))
+			}
+		}
+	}
+
+	// Reduce multiple changes to the same record to their net effect
+	absorbed := make(map[int]int)
+	if d.config.Coalesce {
+		for group, positions := range groupedRecs {
+			kept, tableAbsorbed := coalesce(recs, positions)
+			groupedRecs[group] = kept
+			for pos, target := range tableAbsorbed {
+				absorbed[pos] = target
+			}
+		}
+		sdk.Logger(ctx).Info().Msg(fmt.Sprintf("Number of records coalesced: %d", len(absorbed)))
 	}
 	checkpointTime := time.Now()
 	sdk.Logger(ctx).Info().Msg(fmt.Sprintf("Time taken to group records: %s", checkpointTime.Sub(startTime)))
 
-	//TODO: perhaps goroutines here - one for each table operation group
-	// Step 2: Iterate over the grouped records and process them
-	for table, tableOperations := range groupedPayloads {
-		for operation, opPayloads := range tableOperations {
+	// Step 2: Write each table in its own goroutine, with at most MaxConcurrency
+	// tables in flight. Every goroutine only touches the positions of its own
+	// table in written, so no locking is needed. Tables folded into a parent
+	// are written afterwards, one at a time, so that parents created in the
+	// same batch exist by then and no two tables update the same parents at
+	// once.
+	write := func(group tableGroup) error {
+		return d.writeTable(ctx, group.target, group.table, recs, originals, groupedRecs[group], written)
+	}
+	parents, folded := d.splitFolded(tables)
+	err := d.writeGroups(parents, write)
+	for _, group := range folded {
+		if err != nil {
+			break
+		}
+		err = write(group)
+	}
+	resolveCoalesced(absorbed, written)
+
+	duration := time.Since(checkpointTime)
+	sdk.Logger(ctx).Info().Msg(fmt.Sprintf("Time taken to process records: %s", duration))
+
+	n := writtenCount(written)
+	if err != nil {
+		sdk.Logger(ctx).Error().Msg("Failed to process records: " + err.Error())
+		return n, err
+	}
+
+	return n, nil
+}
+
+// writeGroups calls write for every table group in its own goroutine, with at
+// most MaxConcurrency groups in flight, and returns the errors of all groups.
+func (d *Destination) writeGroups(tables []tableGroup, write func(tableGroup) error) error {
+	errs := make([]error, len(tables))
+	sem := make(chan struct{}, d.config.MaxConcurrency)
+	var wg sync.WaitGroup
+	for i, group := range tables {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			errs[i] = write(group)
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// splitFolded splits table groups into the ones that are written on their own
+// and the ones folded into a parent by meta or embed settings. Folded groups are
+// ordered so that every table comes after the table it is folded into.
+func (d *Destination) splitFolded(tables []tableGroup) ([]tableGroup, []tableGroup) {
+	var parents, folded []tableGroup
+	for _, group := range tables {
+		if d.foldDepth(group.table) == 0 {
+			parents = append(parents, group)
+		} else {
+			folded = append(folded, group)
+		}
+	}
+	slices.SortStableFunc(folded, func(a, b tableGroup) int {
+		return cmp.Compare(d.foldDepth(a.table), d.foldDepth(b.table))
+	})
+	return parents, folded
+}
+
+// writtenCount returns the number of records that can be reported as written.
+// Only the records up to the first one that wasn't written count, even if
+// records of other tables after it did succeed.
+func writtenCount(written []bool) int {
+	n := 0
+	for n < len(written) && written[n] {
+		n++
+	}
+	return n
+}
+
+// prepareRecord maps a record to its table and processes its payload.
+func (d *Destination) prepareRecord(ctx context.Context, rec *opencdc.Record) (string, error) {
+	tableName, err := d.getTableName(*rec)
+	if err != nil {
+		return "", err
+	}
+	// pass the record to processRec function to be prepared for insertion
+	if err := d.processPayload(ctx, rec); err != nil {
+		return tableName, fmt.Errorf("failed to process record: %w", err)
+	}
+	return tableName, nil
+}
+
+// tableGroup is a table of a target, which the records of a batch are grouped
+// by. Tables of the same name in different targets are written separately.
+type tableGroup struct {
+	target *target
+	table  string
+}
+
+// writeTable writes the records of a single table, given by their positions in
+// recs, in the order they arrived. Consecutive records with the same operation
+// are written together. Writing stops at the first failure so that later
+// changes are never applied before earlier ones, unless the failed records are
+// put into the dead letter table. originals holds the records as they arrived
+// if dead letters are enabled.
+func (d *Destination) writeTable(ctx context.Context, t *target, table string, recs, originals []opencdc.Record, positions []int, written []bool) error {
+	for start := 0; start < len(positions); {
+		operation := recs[positions[start]].Operation
+		end := start + 1
+		for end < len(positions) && sameWriteOperation(operation, recs[positions[end]].Operation) {
+			end++
+		}
+		run := positions[start:end]
+
+		payloads := make([]*opencdc.Data, len(run))
+		runRecs := make([]*opencdc.Record, len(run))
+		for i, pos := range run {
+			payloads[i] = &recs[pos].Payload.After
+			runRecs[i] = &recs[pos]
+		}
+
+		var entries []map[string]interface{}
+		if d.logsChanges() {
 			var err error
-			switch operation {
-			case opencdc.OperationSnapshot, opencdc.OperationCreate:
-				err = d.insert(ctx, table, opPayloads)
-			case opencdc.OperationUpdate:
-				err = d.update(ctx, table, opPayloads)
-			case opencdc.OperationDelete:
-				err = d.delete(ctx, table, opPayloads)
-			default:
-				return 0, fmt.Errorf("invalid operation %q", operation)
+			if entries, err = d.changeLogEntries(table, recs, run); err != nil {
+				return fmt.Errorf("failed to log changes of table %s: %w", table, err)
 			}
-			if err != nil {
-				//TODO: evaluate if should cancel the batch if an error occurs
-				// Probably better to send to DLQ, which should be defined in the pipeline config. Could be a specifc NATS JS subject
-				//log the error
-				sdk.Logger(ctx).Error().Msg("Failed to process records: " + err.Error())
-				//return 0, err
+		}
+
+		// writing may change the payloads, so keep a copy for writing them
+		// again after a conflict or one by one
+		copies := make([]opencdc.Data, len(run))
+		for i, payload := range payloads {
+			copies[i] = (*payload).Clone()
+		}
+
+		landed, err := d.writeRecords(ctx, t, table, operation, payloads, entries, runRecs)
+		err = retryConflicts(ctx, landed, err, func(retried []int) ([]bool, error) {
+			sdk.Logger(ctx).Warn().Msg(fmt.Sprintf("Writing %d records of table %s again after a conflict", len(retried), table))
+			retryPayloads := make([]*opencdc.Data, len(retried))
+			retryRecs := make([]*opencdc.Record, len(retried))
+			var retryEntries []map[string]interface{}
+			for j, i := range retried {
+				payload := copies[i].Clone()
+				retryPayloads[j] = &payload
+				retryRecs[j] = runRecs[i]
+				if entries != nil {
+					retryEntries = append(retryEntries, entries[i])
+				}
 			}
+			return d.writeRecords(ctx, t, table, operation, retryPayloads, retryEntries, retryRecs)
+		})
+		for i, pos := range run {
+			written[pos] = landed[i]
+		}
+		if err != nil && originals != nil && isQueryError(err) {
+			err = d.deadLetterRun(ctx, t, table, recs, originals, run, copies, entries, written, err)
 		}
+		if err != nil {
+			return fmt.Errorf("failed to write table %s: %w", table, err)
+		}
+		start = end
 	}
+	return nil
+}
 
-	duration := time.Since(checkpointTime)
-	sdk.Logger(ctx).Info().Msg(fmt.Sprintf("Time taken to process records: %s", duration))
+// maxConflictRetries is the number of times payloads are written again after a
+// transaction conflict that SurrealDB reports can be retried.
+const maxConflictRetries = 3
+
+// conflictBackoff is the wait before payloads are written again after a
+// conflict, doubled for every further attempt.
+const conflictBackoff = 100 * time.Millisecond
+
+// retryConflicts writes the payloads of a run that didn't land again with write
+// as long as err, the error of the last attempt, is a conflict that can be
+// retried, at most maxConflictRetries times. write gets the indexes of the
+// payloads, and landed is updated with the ones that landed. It returns the
+// error of the last attempt.
+func retryConflicts(ctx context.Context, landed []bool, err error, write func(retried []int) ([]bool, error)) error {
+	backoff := conflictBackoff
+	for attempt := 0; attempt < maxConflictRetries && err != nil && isRetryable(err); attempt++ {
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+
+		var retried []int
+		for i, ok := range landed {
+			if !ok {
+				retried = append(retried, i)
+			}
+		}
+		if len(retried) == 0 {
+			return err
+		}
+		var retriedLanded []bool
+		retriedLanded, err = write(retried)
+		for j, i := range retried {
+			landed[i] = j < len(retriedLanded) && retriedLanded[j]
+		}
+	}
+	return err
+}
 
-	return len(recs), nil
+// writeRecords writes payloads of a single table that share the same operation
+// together with their change log entries, if there are any, and checkpoints the
+// positions of rs, the records of the payloads, if enabled.
+func (d *Destination) writeRecords(ctx context.Context, t *target, table string, operation opencdc.Operation, payloads []*opencdc.Data, entries []map[string]interface{}, rs []*opencdc.Record) ([]bool, error) {
+	switch {
+	case d.config.Checkpoints:
+		return d.checkpointedWrite(ctx, t, table, operation, payloads, entries, rs)
+	case entries != nil:
+		return d.loggedWrite(ctx, t, table, operation, payloads, entries, rs)
+	default:
+		return d.writeRun(ctx, t, table, operation, payloads, rs)
+	}
+}
+
+// writeRun writes payloads of a single table that share the same operation and
+// reports which of them were written. rs are the records of the payloads.
+func (d *Destination) writeRun(ctx context.Context, t *target, table string, operation opencdc.Operation, payloads []*opencdc.Data, rs []*opencdc.Record) ([]bool, error) {
+	tc := d.tableConfig(table)
+	switch {
+	case tc.Meta != nil:
+		return d.metaWrite(ctx, t, table, operation, payloads, rs)
+	case tc.Embed != nil:
+		return d.embeddedWrite(ctx, t, table, tc.Embed, operation, payloads, rs)
+	default:
+		return d.writeChanges(ctx, t, table, operation, payloads)
+	}
+}
+
+// writeChanges writes payloads of a single table that share the same operation
+// to the table itself and reports which of them were written.
+func (d *Destination) writeChanges(ctx context.Context, t *target, table string, operation opencdc.Operation, payloads []*opencdc.Data) ([]bool, error) {
+	var n int
+	var err error
+	switch operation {
+	case opencdc.OperationSnapshot, opencdc.OperationCreate:
+		if d.config.VersionField != "" {
+			landed, _, err := d.versionedWrite(ctx, t, table, operation, payloads)
+			return landed, err
+		}
+		return d.insertSplitting(ctx, t, table, payloads)
+	case opencdc.OperationUpdate:
+		if d.config.VersionField != "" {
+			landed, _, err := d.versionedWrite(ctx, t, table, operation, payloads)
+			return landed, err
+		}
+		n, err = d.update(ctx, t, table, payloads)
+	case opencdc.OperationDelete:
+		if d.config.VersionField != "" {
+			landed, _, err := d.versionedWrite(ctx, t, table, operation, payloads)
+			return landed, err
+		}
+		n, err = d.delete(ctx, t, table, payloads)
+	default:
+		err = fmt.Errorf("invalid operation %q", operation)
+	}
+
+	landed := make([]bool, len(payloads))
+	for i := range landed[:n] {
+		landed[i] = true
+	}
+	return landed, err
+}
+
+// sameWriteOperation reports whether records with the operations a and b can be
+// written in the same request. Snapshots and creates are both inserts.
+func sameWriteOperation(a, b opencdc.Operation) bool {
+	isInsert := func(op opencdc.Operation) bool {
+		return op == opencdc.OperationSnapshot || op == opencdc.OperationCreate
+	}
+	return a == b || (isInsert(a) && isInsert(b))
 }
 
 func (d *Destination) Teardown(_ context.Context) error {
 	// Teardown signals to the plugin that all records were written and there
 	// will be no more calls to any other function. After Teardown returns, the
 	// plugin should be ready for a graceful shutdown.
-	if d.db != nil {
-		if err := d.db.Invalidate(); err != nil {
-			return fmt.Errorf("failed to invalidate token: %w", err)
-		}
-		return d.db.Close()
+	var errs []error
+	for _, t := range d.targets {
+		errs = append(errs, t.close())
 	}
-	return nil
+	return errors.Join(errs...)
 }
 
-func loadRelationSchema(filepath string) ([]RelationEventConfig, error) {
+func loadRelationSchema(filepath string) (RelationSchema, error) {
+	var schema RelationSchema
 	data, err := os.ReadFile(filepath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read schema file: %w", err)
+		return schema, fmt.Errorf("failed to read schema file: %w", err)
 	}
 
-	var schema RelationSchema
 	if err := yaml.Unmarshal(data, &schema); err != nil {
-		return nil, fmt.Errorf("failed to parse schema: %w", err)
+		return schema, fmt.Errorf("failed to parse schema: %w", err)
 	}
 
-	return schema.Relations, nil
+	return schema, nil
 }
 
-func createRelationEvent(db *surrealdb.DB, config RelationEventConfig) error {
+func createRelationEvent(db *surrealdb.DB, config RelationEventConfig, metadataField string) error {
 	/* TODO: need to add something to allow for the conditionals to specified
 	- eg. perhaps event = create is default, likewise after.inField != NONE is default
 	- but also want to allow for other conditions to be specified
@@ -238,7 +559,7 @@ THEN {
             type::thing('{{.OutTable}}', $after.{{.Trigger.OutField}})
         };
     
-    RELATE $in->{{.Name}}->$out;
+    RELATE $in->{{.Name}}->$out{{if .MetadataField}} SET {{.MetadataField}} = $after.{{.MetadataField}}{{end}};
 };
 
 DEFINE INDEX {{.Name}}_unique_relationship 
@@ -246,7 +567,11 @@ ON TABLE {{.Name}}
 COLUMNS in, out UNIQUE;`))
 
 	var query bytes.Buffer
-	if err := tmpl.Execute(&query, config); err != nil {
+	data := struct {
+		RelationEventConfig
+		MetadataField string
+	}{config, metadataField}
+	if err := tmpl.Execute(&query, data); err != nil {
 		return err
 	}
 
@@ -265,7 +590,7 @@ COLUMNS in, out UNIQUE;`))
 }
 
 // Receive record and return pointer to modified payload
-func (d *Destination) processPayload(r *opencdc.Record) error {
+func (d *Destination) processPayload(ctx context.Context, r *opencdc.Record) error {
 
 	//ensure payload is map[string]interface{}
 	err := d.structuredDataFormatter(&r.Payload.After)
@@ -273,14 +598,34 @@ func (d *Destination) processPayload(r *opencdc.Record) error {
 		return fmt.Errorf("failed to get payload: %w", err)
 	}
 
+	if err := d.normalizeKey(r); err != nil {
+		return err
+	}
 	// get the first key column name, in case there are many primary keys
 	keyColumn, err := d.getKeyColumnName(r.Key)
 	if err != nil {
 		return err
 	}
 
+	// deletes usually come without a payload, so identify the record by its key
+	if r.Payload.After == nil && r.Operation == opencdc.OperationDelete {
+		afterMap := make(opencdc.StructuredData)
+		for k, v := range r.Key.(opencdc.StructuredData) {
+			afterMap[k] = v
+		}
+		r.Payload.After = afterMap
+	}
+
 	// Perform a type assertion to access the underlying map
 	if afterMap, ok := r.Payload.After.(opencdc.StructuredData); ok {
+		// payloads don't always repeat their key, e.g. when the key is a scalar
+		if _, exists := afterMap["id"]; !exists {
+			if _, exists := afterMap[keyColumn]; !exists {
+				if keyMap, ok := r.Key.(opencdc.StructuredData); ok && keyMap[keyColumn] != nil {
+					afterMap["id"] = keyMap[keyColumn]
+				}
+			}
+		}
 		if keyColumn != "id" {
 			// Set afterMap["id"] to the value of afterMap[keyColumn]
 			if value, exists := afterMap[keyColumn]; exists {
@@ -296,74 +641,287 @@ func (d *Destination) processPayload(r *opencdc.Record) error {
 	} else {
 		return fmt.Errorf("unexpected type for r.Payload.After: %T", r.Payload.After)
 	}
+	if d.config.FieldNames != fieldNamesKeep || d.config.SanitizeFieldNames {
+		normalized, err := d.normalizeFieldNames(r.Payload.After.(opencdc.StructuredData))
+		if err != nil {
+			return err
+		}
+		r.Payload.After = normalized
+	}
+
+	tableName, err := d.getTableName(*r)
+	if err != nil {
+		return err
+	}
+	d.projectPayload(tableName, r)
+	if mc := d.tableConfig(tableName).Meta; mc != nil {
+		if err := d.prepareMeta(mc, r); err != nil {
+			return err
+		}
+	}
+	if ec := d.tableConfig(tableName).Embed; ec != nil {
+		if err := d.prepareEmbed(ec, r); err != nil {
+			return err
+		}
+	}
+	d.applyNulls(tableName, r.Payload.After.(opencdc.StructuredData))
+	if err := d.applyMappedFields(r, r.Payload.After.(opencdc.StructuredData)); err != nil {
+		return err
+	}
+	if err := d.coerceTypes(ctx, r); err != nil {
+		return err
+	}
+	if afterMap, ok := r.Payload.After.(opencdc.StructuredData); ok {
+		d.applyLinks(tableName, afterMap)
+		if err := d.applyGeometry(tableName, afterMap); err != nil {
+			return err
+		}
+		if err := d.applyEmbeddings(tableName, afterMap); err != nil {
+			return err
+		}
+	}
+
+	if d.config.MetadataField != "" {
+		d.setRecordMetadata(r, r.Payload.After.(opencdc.StructuredData))
+	}
+
+	if d.config.VersionField != "" {
+		if err := d.setVersion(r); err != nil {
+			return err
+		}
+	}
 
 	return err
 }
 
-func (d *Destination) insert(ctx context.Context, tableName string, payloads []*opencdc.Data) error {
+func (d *Destination) insert(ctx context.Context, t *target, tableName string, payloads []*opencdc.Data) (int, error) {
 
 	// This only works partially. Problem is that bulk insert doesnt support using `ON DUPLICATE KEY UPDATE`, which can be used for single inserts. So if a bulk insert has an existing key, the whole batch will fail.
 	// Given that Bulk Upsert doesnt exist yet (https://github.com/surrealdb/surrealdb/pull/4455), perhaps should make this loop through all records and insert one by one for now, so that at least it'll work rather than fail? Or, if we're just doing one by one, should normal Upsert be used instead of Insert?
 
-	if _, err := surrealdb.Insert[interface{}](d.db, models.Table(tableName), payloads); err != nil {
+	if len(d.foldedFields(tableName)) > 0 {
+		// meta rows may have created the records already
+		if err := d.upsert(t, tableName, payloads); err != nil {
+			sdk.Logger(ctx).Error().Msg("Failed to insert record: " + err.Error())
+			return 0, fmt.Errorf("failed to insert record: %w", err)
+		}
+		return len(payloads), nil
+	}
+	if _, err := surrealdb.Insert[interface{}](t.db, models.Table(tableName), payloads); err != nil {
 		sdk.Logger(ctx).Error().Msg("Failed to insert record: " + err.Error())
-		return fmt.Errorf("failed to insert record: %w", err)
+		return 0, fmt.Errorf("failed to insert record: %w", err)
 	}
 
-	return nil
+	return len(payloads), nil
 }
 
-func (d *Destination) update(ctx context.Context, tableName string, payloads []*opencdc.Data) error {
+// insertSplitting inserts payloads in a single request. If SurrealDB rejects
+// the request, the payloads are bisected and the halves retried until the
+// records causing the failure are isolated, so that only those are failed and
+// the rest of the batch still lands. It reports which payloads were inserted.
+func (d *Destination) insertSplitting(ctx context.Context, t *target, tableName string, payloads []*opencdc.Data) ([]bool, error) {
+	return splitWrites(tableName, payloads, func(lo, hi int) error {
+		_, err := d.insert(ctx, t, tableName, payloads[lo:hi])
+		return err
+	})
+}
 
-	//right now Update doesnt support batched transactions, so need to loop the payloads and update one by one. Variable is "tableName" for now, but is really just a single record. It'll be a table when bulk update/upsert is supported
-	for _, payload := range payloads {
+// splitWrites calls write for all payloads, given as a range of their indexes,
+// and bisects ranges that SurrealDB rejects down to the single payloads that
+// fail. It reports which payloads were written.
+func splitWrites(tableName string, payloads []*opencdc.Data, write func(lo, hi int) error) ([]bool, error) {
+	landed := make([]bool, len(payloads))
+	var errs []error
+
+	var split func(lo, hi int) error
+	split = func(lo, hi int) error {
+		err := write(lo, hi)
+		if err == nil {
+			for i := lo; i < hi; i++ {
+				landed[i] = true
+			}
+			return nil
+		}
+		// Only errors returned by SurrealDB itself point at the data, anything
+		// else (e.g. a dropped connection) would fail every half as well.
+		if !isQueryError(err) {
+			return err
+		}
+		if hi-lo == 1 {
+			errs = append(errs, fmt.Errorf("record %s: %w", recordName(tableName, payloads[lo]), err))
+			return nil
+		}
+		mid := lo + (hi-lo)/2
+		if err := split(lo, mid); err != nil {
+			return err
+		}
+		return split(mid, hi)
+	}
+
+	if err := split(0, len(payloads)); err != nil {
+		return landed, err
+	}
+	return landed, errors.Join(errs...)
+}
+
+// isQueryError reports whether err was returned by SurrealDB for the request,
+// as opposed to a failure to reach it. Transaction conflicts that SurrealDB
+// reports can be retried don't count, as the request may succeed when it is
+// written again.
+func isQueryError(err error) bool {
+	if isRetryable(err) {
+		return false
+	}
+	var rpcErr *connection.RPCError
+	var stmtErr statementError
+	return errors.As(err, &rpcErr) || errors.As(err, &stmtErr)
+}
+
+// isRetryable reports whether err holds a read or write conflict between
+// concurrent transactions, which SurrealDB reports as one that "can be
+// retried".
+func isRetryable(err error) bool {
+	return strings.Contains(err.Error(), "can be retried")
+}
+
+// statementError is the result SurrealDB returned for a statement of a query
+// that failed.
+type statementError struct {
+	result interface{}
+}
+
+func (e statementError) Error() string {
+	return fmt.Sprint(e.result)
+}
+
+// recordName returns the "table:id" name of the record held in payload, for use
+// in errors and logs.
+func recordName(tableName string, payload *opencdc.Data) string {
+	if payloadMap, ok := (*payload).(opencdc.StructuredData); ok {
+		return tableName + ":" + fmt.Sprintf("%v", payloadMap["id"])
+	}
+	return tableName
+}
+
+func (d *Destination) update(ctx context.Context, t *target, tableName string, payloads []*opencdc.Data) (int, error) {
+
+	//right now Update doesnt support batched transactions, so need to loop the payloads and update one by one. Variable is "recordID" for now, but is really just a single record. It'll be a table when bulk update/upsert is supported
+	for i, payload := range payloads {
 		//append id to tableName with colon
+		var recordID models.RecordID
 		if payloadMap, ok := (*payload).(opencdc.StructuredData); ok {
-			tableName = tableName + ":" + fmt.Sprintf("%v", payloadMap["id"])
+			recordID = models.NewRecordID(tableName, payloadMap["id"])
 			//remove id from payload as it conflicts with Update/Delete commands
 			delete(payloadMap, "id")
 			*payload = payloadMap
 		} else {
-			return fmt.Errorf("unexpected type for payload: %T", *payload)
+			return i, fmt.Errorf("unexpected type for payload: %T", *payload)
 		}
 
 		//TODO: Update function doesnt actually seem to work. Nor does upsert or merge.
-		if _, err := surrealdb.Update[interface{}](d.db, models.Table(tableName), payload); err != nil {
+		if len(d.foldedFields(tableName)) > 0 {
+			// Update replaces the whole record, including the fields other
+			// tables are folded into
+			if err := d.replace(t, tableName, recordID, payload); err != nil {
+				sdk.Logger(ctx).Error().Msg("Failed to insert record: " + err.Error())
+				return i, fmt.Errorf("failed to insert record: %w", err)
+			}
+			continue
+		}
+		if _, err := surrealdb.Update[interface{}](t.db, recordID, payload); err != nil {
 			sdk.Logger(ctx).Error().Msg("Failed to insert record: " + err.Error())
-			return fmt.Errorf("failed to insert record: %w", err)
+			return i, fmt.Errorf("failed to insert record: %w", err)
+		}
+
+	}
+	return len(payloads), nil
+}
+
+// replace replaces the content of a record with payload like Update, keeping
+// the fields other tables are folded into.
+func (d *Destination) replace(t *target, tableName string, recordID models.RecordID, payload *opencdc.Data) error {
+	vars := map[string]interface{}{
+		"tb":   tableName,
+		"id":   recordID.ID,
+		"data": *payload,
+	}
+	results, err := surrealdb.Query[interface{}](t.db, "UPDATE type::thing($tb, $id) CONTENT "+d.content(tableName, "$data")+";", vars)
+	if err != nil {
+		return err
+	}
+	for _, result := range *results {
+		if result.Status != "OK" {
+			return statementError{result.Result}
+		}
+	}
+	return nil
+}
+
+// upsert creates the records held in payloads, or replaces the content of the
+// ones that exist already, keeping the fields other tables are folded into.
+func (d *Destination) upsert(t *target, tableName string, payloads []*opencdc.Data) error {
+	var query strings.Builder
+	vars := map[string]interface{}{"tb": tableName}
+	if err := d.upsertStatements(&query, vars, tableName, payloads); err != nil {
+		return err
+	}
+	results, err := surrealdb.Query[interface{}](t.db, query.String(), vars)
+	if err != nil {
+		return err
+	}
+	for _, result := range *results {
+		if result.Status != "OK" {
+			return statementError{result.Result}
 		}
+	}
+	return nil
+}
 
+// upsertStatements appends one statement per payload to query that creates the
+// record in tableName, given by $tb, or replaces its content the way replace
+// does, and adds the variables they use to vars.
+func (d *Destination) upsertStatements(query *strings.Builder, vars map[string]interface{}, tableName string, payloads []*opencdc.Data) error {
+	for i, payload := range payloads {
+		payloadMap, ok := (*payload).(opencdc.StructuredData)
+		if !ok {
+			return fmt.Errorf("unexpected type for payload: %T", *payload)
+		}
+		vars[fmt.Sprintf("id%d", i)] = payloadMap["id"]
+		vars[fmt.Sprintf("data%d", i)] = withoutID(payloadMap)
+		fmt.Fprintf(query, "UPSERT type::thing($tb, $id%d) CONTENT %s;\n", i, d.content(tableName, fmt.Sprintf("$data%d", i)))
 	}
 	return nil
 }
 
-func (d *Destination) delete(ctx context.Context, tableName string, payloads []*opencdc.Data) error {
+func (d *Destination) delete(ctx context.Context, t *target, tableName string, payloads []*opencdc.Data) (int, error) {
 
-	//right now Update doesnt support batched transactions, so need to loop the payloads and delete one by one. Variable is "tableName" for now, but is really just a single record. It'll be a table when bulk delete is supported
-	for _, payload := range payloads {
+	//right now Update doesnt support batched transactions, so need to loop the payloads and delete one by one. Variable is "recordID" for now, but is really just a single record. It'll be a table when bulk delete is supported
+	for i, payload := range payloads {
 		//append id to tableName with colon
+		var recordID models.RecordID
 		if payloadMap, ok := (*payload).(opencdc.StructuredData); ok {
-			tableName = tableName + ":" + fmt.Sprintf("%v", payloadMap["id"])
+			recordID = models.NewRecordID(tableName, payloadMap["id"])
 			// //remove id from payload
 			// delete(payloadMap, "id")
 			// *payload = payloadMap
 		} else {
-			return fmt.Errorf("unexpected type for payload: %T", *payload)
+			return i, fmt.Errorf("unexpected type for payload: %T", *payload)
 		}
 
-		if _, err := surrealdb.Delete[interface{}](d.db, models.Table(tableName)); err != nil {
+		if _, err := surrealdb.Delete[interface{}](t.db, recordID); err != nil {
 			sdk.Logger(ctx).Error().Msg("Failed to insert record: " + err.Error())
-			return fmt.Errorf("failed to insert record: %w", err)
+			return i, fmt.Errorf("failed to insert record: %w", err)
 		}
 	}
-	return nil
+	return len(payloads), nil
 }
 
 func (d *Destination) getTableName(r opencdc.Record) (string, error) {
-	if val, ok := r.Metadata["opencdc.collection"]; !ok || val == "" {
-		return "", nil
+	mapped, err := d.mapTable(r)
+	if err != nil {
+		return "", err
 	}
-	return r.Metadata["opencdc.collection"], nil
+	return mapped.Name, nil
 }
 
 func (d *Destination) structuredDataFormatter(data *opencdc.Data) error {
@@ -374,6 +932,9 @@ func (d *Destination) structuredDataFormatter(data *opencdc.Data) error {
 		return nil
 	}
 	if sdata, ok := (*data).(opencdc.StructuredData); ok {
+		if err := d.normalizeNumbers(sdata); err != nil {
+			return err
+		}
 		*data = sdata
 		return nil
 	}
@@ -383,11 +944,26 @@ func (d *Destination) structuredDataFormatter(data *opencdc.Data) error {
 		return nil
 	}
 
+	// decode numbers as json.Number, as float64 would silently corrupt
+	// integers above 2^53 and decimals
 	m := make(map[string]interface{})
-	err := json.Unmarshal(raw, &m)
-	if err != nil {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&m); err != nil {
+		if d.storesRawPayloads() {
+			return d.wrapRawPayload(data, raw)
+		}
 		return fmt.Errorf("failed to unmarshal data: %w", err)
 	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		if d.storesRawPayloads() {
+			return d.wrapRawPayload(data, raw)
+		}
+		return fmt.Errorf("failed to unmarshal data: unexpected data after JSON object")
+	}
+	if err := d.normalizeNumbers(m); err != nil {
+		return err
+	}
 	*data = opencdc.StructuredData(m)
 	return nil
 }
@@ -401,17 +977,17 @@ func (d *Destination) getKeyColumnName(key opencdc.Data) (string, error) {
 		return "", fmt.Errorf("unexpected type for key: %T", key)
 	}
 
-	if len(structuredKey) > 1 {
-		// Go maps aren't order preserving, so anything over len 1 will have
-		// non deterministic results until we handle composite keys.
-		panic("composite keys not yet supported")
-	}
-
 	// if key "id" is set (be there 1 or more keys) return id
 	if _, ok := structuredKey["id"]; ok {
 		return "id", nil
 	}
 
+	if len(structuredKey) > 1 {
+		// Go maps aren't order preserving, so anything over len 1 will have
+		// non deterministic results until we handle composite keys.
+		return "", fmt.Errorf("composite keys are not supported, key has fields %s", strings.Join(slices.Sorted(maps.Keys(structuredKey)), ", "))
+	}
+
 	// TODO: this must be addressed on the source connector side as it should only be sending a single key so that we don't have to arbitrarily select here.
 	// otherwise arbitrarily return the first key in the key map
 	for k := range structuredKey {
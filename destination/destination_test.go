@@ -0,0 +1,127 @@
+package destination
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/conduitio/conduit-commons/opencdc"
+	"github.com/matryer/is"
+	"github.com/surrealdb/surrealdb.go/pkg/connection"
+)
+
+func TestWriteGroups(t *testing.T) {
+	is := is.New(t)
+	d := &Destination{config: Config{MaxConcurrency: 2}}
+
+	// records 0, 2 and 4 belong to wp_posts, 1 and 5 to wp_users and 3 to
+	// wp_comments, which fails after its first record
+	tables := []tableGroup{{table: "wp_posts"}, {table: "wp_users"}, {table: "wp_comments"}}
+	positions := map[string][]int{
+		"wp_posts":    {0, 2, 4},
+		"wp_users":    {1, 5},
+		"wp_comments": {3, 6},
+	}
+	written := make([]bool, 7)
+	err := d.writeGroups(tables, func(group tableGroup) error {
+		for _, pos := range positions[group.table] {
+			if pos == 6 {
+				return errors.New("failed to write table wp_comments")
+			}
+			written[pos] = true
+		}
+		return nil
+	})
+	is.True(err != nil)
+	is.True(strings.Contains(err.Error(), "wp_comments"))
+	is.Equal(written, []bool{true, true, true, true, true, true, false})
+	is.Equal(writtenCount(written), 6)
+
+	// a failure early in the batch hides the records written after it
+	written = []bool{true, false, true, true}
+	is.Equal(writtenCount(written), 1)
+	is.Equal(writtenCount(nil), 0)
+
+	is.NoErr(d.writeGroups(tables, func(tableGroup) error { return nil }))
+}
+
+func TestSplitWrites(t *testing.T) {
+	is := is.New(t)
+
+	payloads := make([]*opencdc.Data, 8)
+	for i := range payloads {
+		var data opencdc.Data = opencdc.StructuredData{"id": i}
+		payloads[i] = &data
+	}
+
+	// record 5 is rejected by SurrealDB, which fails every range holding it
+	var calls [][2]int
+	landed, err := splitWrites("wp_posts", payloads, func(lo, hi int) error {
+		calls = append(calls, [2]int{lo, hi})
+		if lo <= 5 && 5 < hi {
+			return fmt.Errorf("rpc request err %w", &connection.RPCError{Message: "invalid value"})
+		}
+		return nil
+	})
+	is.True(err != nil)
+	is.True(strings.Contains(err.Error(), "record wp_posts:5"))
+	is.Equal(landed, []bool{true, true, true, true, true, false, true, true})
+	is.Equal(calls, [][2]int{{0, 8}, {0, 4}, {4, 8}, {4, 6}, {4, 5}, {5, 6}, {6, 8}})
+
+	// failures to reach SurrealDB aren't bisected
+	calls = nil
+	landed, err = splitWrites("wp_posts", payloads, func(lo, hi int) error {
+		calls = append(calls, [2]int{lo, hi})
+		return errors.New("connection closed")
+	})
+	is.Equal(err.Error(), "connection closed")
+	is.Equal(landed, make([]bool, 8))
+	is.Equal(len(calls), 1)
+
+	// neither are conflicts that SurrealDB reports can be retried
+	calls = nil
+	_, err = splitWrites("wp_posts", payloads, func(lo, hi int) error {
+		calls = append(calls, [2]int{lo, hi})
+		return fmt.Errorf("rpc request err %w", &connection.RPCError{Message: "read or write conflict. This transaction can be retried"})
+	})
+	is.True(err != nil)
+	is.Equal(len(calls), 1)
+}
+
+func TestRetryConflicts(t *testing.T) {
+	is := is.New(t)
+	conflict := fmt.Errorf("rpc request err %w", &connection.RPCError{Message: "read or write conflict. This transaction can be retried"})
+
+	// only the payloads that didn't land are written again
+	landed := []bool{true, false, false}
+	var calls [][]int
+	err := retryConflicts(context.Background(), landed, conflict, func(retried []int) ([]bool, error) {
+		calls = append(calls, retried)
+		if len(calls) == 1 {
+			return []bool{true, false}, conflict
+		}
+		return []bool{true}, nil
+	})
+	is.NoErr(err)
+	is.Equal(landed, []bool{true, true, true})
+	is.Equal(calls, [][]int{{1, 2}, {2}})
+
+	// attempts are bounded
+	calls = nil
+	err = retryConflicts(context.Background(), []bool{false}, conflict, func(retried []int) ([]bool, error) {
+		calls = append(calls, retried)
+		return []bool{false}, conflict
+	})
+	is.Equal(err, conflict)
+	is.Equal(len(calls), maxConflictRetries)
+
+	// other errors aren't retried
+	other := errors.New("connection closed")
+	err = retryConflicts(context.Background(), []bool{false}, other, func([]int) ([]bool, error) {
+		t.Fatal("unexpected retry")
+		return nil, nil
+	})
+	is.Equal(err, other)
+}
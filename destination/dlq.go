@@ -0,0 +1,174 @@
+package destination
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/conduitio/conduit-commons/opencdc"
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/surrealdb/surrealdb.go"
+)
+
+// deadLetterRun writes the records of a run that failed and weren't written one
+// by one, starting again from copies of their payloads, and puts the ones that
+// SurrealDB rejects into the dead letter table. It stops at the first failure
+// that isn't a rejection, e.g. a dropped connection. If every record of the run
+// was written, cause, the error of the run, is returned as it is, as it didn't
+// come from a record.
+func (d *Destination) deadLetterRun(ctx context.Context, t *target, table string, recs, originals []opencdc.Record, run []int, copies []opencdc.Data, entries []map[string]interface{}, written []bool, cause error) error {
+	retried := false
+	for i, pos := range run {
+		if written[pos] {
+			continue
+		}
+		retried = true
+		payload := copies[i]
+		var entry []map[string]interface{}
+		if entries != nil {
+			entry = entries[i : i+1]
+		}
+
+		landed, err := d.writeRecords(ctx, t, table, recs[pos].Operation, []*opencdc.Data{&payload}, entry, []*opencdc.Record{&recs[pos]})
+		if err == nil && landed[0] {
+			written[pos] = true
+			continue
+		}
+		if err == nil {
+			return fmt.Errorf("record %s wasn't written", recordName(table, &copies[i]))
+		}
+		if !isQueryError(err) {
+			return err
+		}
+		if err := d.deadLetter(ctx, t, table, originals[pos], err); err != nil {
+			return err
+		}
+		written[pos] = true
+	}
+	if !retried {
+		return cause
+	}
+	return nil
+}
+
+// deadLetter puts a record that failed into the dead letter table of a target,
+// together with the error, the table it was written to and the time it failed.
+// Entries get a ULID as id, so that they sort in the order they failed.
+func (d *Destination) deadLetter(ctx context.Context, t *target, table string, r opencdc.Record, cause error) error {
+	sdk.Logger(ctx).Warn().Msg(fmt.Sprintf("Putting record of table %s into %s: %s", table, d.config.DeadLetterTable, cause))
+
+	vars := map[string]interface{}{
+		"dlq_tb": d.config.DeadLetterTable,
+		"record": deadLetterRecord(d.withoutExcluded(table, r)),
+		"error":  cause.Error(),
+		"tb":     table,
+	}
+	results, err := surrealdb.Query[interface{}](t.db, "CREATE type::thing($dlq_tb, rand::ulid()) SET record = $record, error = $error, collection = $tb, failed_at = time::now();", vars)
+	if err == nil {
+		for _, result := range *results {
+			if result.Status != "OK" {
+				err = statementError{result.Result}
+			}
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to put record into %s: %w (record failed with: %v)", d.config.DeadLetterTable, err, cause)
+	}
+	return nil
+}
+
+// withoutExcluded returns r with the fields the table excludes removed from its
+// key and payloads, so that they don't reach SurrealDB through the dead letter
+// table either. Raw JSON objects are encoded again without them.
+func (d *Destination) withoutExcluded(table string, r opencdc.Record) opencdc.Record {
+	exclude := d.tableConfig(table).Fields.Exclude
+	if len(exclude) == 0 {
+		return r
+	}
+	r.Key = d.excludeFields(r.Key, exclude)
+	r.Payload.Before = d.excludeFields(r.Payload.Before, exclude)
+	r.Payload.After = d.excludeFields(r.Payload.After, exclude)
+	return r
+}
+
+// excludeFields returns a copy of data without the excluded fields. Raw data
+// that isn't a JSON object is returned as it is.
+func (d *Destination) excludeFields(data opencdc.Data, exclude []string) opencdc.Data {
+	switch v := data.(type) {
+	case nil:
+		return nil
+	case opencdc.StructuredData:
+		m := v.Clone().(opencdc.StructuredData)
+		d.dropExcluded(m, "", exclude)
+		return m
+	default:
+		m := make(map[string]interface{})
+		dec := json.NewDecoder(bytes.NewReader(data.Bytes()))
+		dec.UseNumber()
+		if err := dec.Decode(&m); err != nil || !d.dropExcluded(m, "", exclude) {
+			return data
+		}
+		raw, err := json.Marshal(m)
+		if err != nil {
+			return data
+		}
+		return opencdc.RawData(raw)
+	}
+}
+
+// dropExcluded deletes the fields of m that are excluded, matching them by the
+// names normalizeFieldNames gives them, and reports whether any were deleted.
+func (d *Destination) dropExcluded(m map[string]interface{}, prefix string, exclude []string) bool {
+	dropped := false
+	for key, value := range m {
+		name := prefix + key
+		if d.config.FieldNames == fieldNamesExpand {
+			name, _, _ = strings.Cut(name, ".")
+		}
+		if slices.Contains(exclude, name) || (d.config.SanitizeFieldNames && slices.Contains(exclude, sanitizeFieldName(name))) {
+			delete(m, key)
+			dropped = true
+			continue
+		}
+		if nested, ok := asObject(value); ok && d.config.FieldNames == fieldNamesFlatten {
+			dropped = d.dropExcluded(nested, name+"_", exclude) || dropped
+		}
+	}
+	return dropped
+}
+
+// deadLetterRecord returns a record the way it is stored in the dead letter
+// table.
+func deadLetterRecord(r opencdc.Record) map[string]interface{} {
+	return map[string]interface{}{
+		"position":  positionValue(r.Position),
+		"operation": r.Operation.String(),
+		"metadata":  map[string]string(r.Metadata),
+		"key":       dataValue(r.Key),
+		"payload": map[string]interface{}{
+			"before": dataValue(r.Payload.Before),
+			"after":  dataValue(r.Payload.After),
+		},
+	}
+}
+
+// dataValue returns structured data as an object, and raw data as a string or,
+// if it isn't text, as bytes.
+func dataValue(data opencdc.Data) interface{} {
+	switch v := data.(type) {
+	case nil:
+		return nil
+	case opencdc.StructuredData:
+		return map[string]interface{}(v)
+	default:
+		raw := data.Bytes()
+		if utf8.Valid(raw) {
+			return string(raw)
+		}
+		return raw
+	}
+}
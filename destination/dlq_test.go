@@ -0,0 +1,87 @@
+package destination
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/conduitio/conduit-commons/opencdc"
+	"github.com/matryer/is"
+	"github.com/surrealdb/surrealdb.go/pkg/connection"
+)
+
+func TestDeadLetterRecord(t *testing.T) {
+	is := is.New(t)
+
+	r := opencdc.Record{
+		Position:  opencdc.Position("7"),
+		Operation: opencdc.OperationCreate,
+		Metadata:  opencdc.Metadata{"opencdc.collection": "wp_posts"},
+		Key:       opencdc.RawData("5"),
+		Payload: opencdc.Change{
+			After: opencdc.StructuredData{"id": 5, "title": "hi"},
+		},
+	}
+	is.Equal(deadLetterRecord(r), map[string]interface{}{
+		"position":  "7",
+		"operation": "create",
+		"metadata":  map[string]string{"opencdc.collection": "wp_posts"},
+		"key":       "5",
+		"payload": map[string]interface{}{
+			"before": nil,
+			"after":  map[string]interface{}{"id": 5, "title": "hi"},
+		},
+	})
+
+	is.Equal(dataValue(opencdc.RawData{0xff, 0x01}), []byte{0xff, 0x01})
+}
+
+func TestWithoutExcluded(t *testing.T) {
+	is := is.New(t)
+	d := &Destination{
+		config: Config{FieldNames: fieldNamesFlatten},
+		tables: map[string]TableConfig{
+			"wp_users": {Fields: FieldsConfig{Exclude: []string{"user_pass", "address_street"}}},
+		},
+	}
+
+	r := opencdc.Record{
+		Key: opencdc.StructuredData{"id": 5},
+		Payload: opencdc.Change{
+			Before: opencdc.RawData(`{"id": 5, "user_pass": "old", "user_login": "ann"}`),
+			After: opencdc.StructuredData{
+				"id":        5,
+				"user_pass": "new",
+				"address":   map[string]interface{}{"street": "Main St", "city": "Berlin"},
+			},
+		},
+	}
+	got := d.withoutExcluded("wp_users", r)
+	is.Equal(got.Key, opencdc.StructuredData{"id": 5})
+	is.Equal(got.Payload.Before, opencdc.RawData(`{"id":5,"user_login":"ann"}`))
+	is.Equal(got.Payload.After, opencdc.StructuredData{
+		"id":      5,
+		"address": opencdc.StructuredData{"city": "Berlin"},
+	})
+	// the record itself is left alone
+	is.Equal(r.Payload.After.(opencdc.StructuredData)["user_pass"], "new")
+
+	is.Equal(d.withoutExcluded("wp_posts", r), r)
+}
+
+func TestIsQueryError(t *testing.T) {
+	is := is.New(t)
+
+	err := fmt.Errorf("record wp_posts:5: %w", statementError{"Found NONE for field title"})
+	is.True(isQueryError(err))
+	is.True(isQueryError(errors.Join(errors.New("other"), err)))
+	is.Equal(err.Error(), "record wp_posts:5: Found NONE for field title")
+	is.True(!isQueryError(errors.New("connection closed")))
+
+	// conflicts are written again instead of being dead-lettered or bisected
+	conflict := fmt.Errorf("rpc request err %w", &connection.RPCError{
+		Message: "There was a problem with the database: Failed to commit transaction due to a read or write conflict. This transaction can be retried",
+	})
+	is.True(!isQueryError(conflict))
+	is.True(!isQueryError(fmt.Errorf("record wp_posts:5: %w", statementError{conflict.Error()})))
+}
@@ -0,0 +1,227 @@
+package destination
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/conduitio/conduit-commons/opencdc"
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/surrealdb/surrealdb.go"
+	"github.com/surrealdb/surrealdb.go/pkg/models"
+)
+
+// EmbedConfig keeps the records of a child table in an array field of their
+// parent records, e.g. the comments of a post in wp_posts:5.comments. Children
+// are still written to their own table as well.
+type EmbedConfig struct {
+	// Parent is the table the children belong to.
+	Parent string `yaml:"parent"`
+	// ParentField holds the id of the parent record, e.g. "comment_post_ID".
+	ParentField string `yaml:"parentField"`
+	// Field is the array field of the parent, the child table by default.
+	Field string `yaml:"field"`
+	// Link stores record links to the children instead of the children
+	// themselves.
+	Link bool `yaml:"link"`
+}
+
+// validate checks the embed settings of a child table and fills in the
+// defaults.
+func (ec *EmbedConfig) validate(tableName string) error {
+	if ec.Parent == "" || ec.ParentField == "" {
+		return fmt.Errorf("parent and parentField are required")
+	}
+	if ec.Field == "" {
+		ec.Field = tableName
+	}
+	if !targetNamePattern.MatchString(ec.Field) {
+		return fmt.Errorf("invalid field %q", ec.Field)
+	}
+	return nil
+}
+
+// prepareEmbed makes sure the payload of a deleted child holds its parent,
+// which deletes usually only carry in the payload before the change. The
+// payload before an update is decoded as well, to find children that moved to
+// another parent.
+func (d *Destination) prepareEmbed(ec *EmbedConfig, r *opencdc.Record) error {
+	afterMap, ok := r.Payload.After.(opencdc.StructuredData)
+	if !ok {
+		return nil
+	}
+	switch {
+	case r.Operation == opencdc.OperationUpdate:
+	case r.Operation == opencdc.OperationDelete && afterMap[ec.ParentField] == nil:
+	default:
+		return nil
+	}
+	if err := d.structuredDataFormatter(&r.Payload.Before); err != nil {
+		return fmt.Errorf("failed to get payload before the change: %w", err)
+	}
+	if beforeMap, ok := r.Payload.Before.(opencdc.StructuredData); ok && beforeMap[ec.ParentField] != nil && afterMap[ec.ParentField] == nil {
+		afterMap[ec.ParentField] = beforeMap[ec.ParentField]
+	}
+	return nil
+}
+
+// movedFrom returns the parents the children of rs had before an update moved
+// them to another parent, with nil for the children that didn't move.
+func movedFrom(ec *EmbedConfig, operation opencdc.Operation, rs []*opencdc.Record) []interface{} {
+	previous := make([]interface{}, len(rs))
+	if operation != opencdc.OperationUpdate {
+		return previous
+	}
+	for i, r := range rs {
+		afterMap, _ := r.Payload.After.(opencdc.StructuredData)
+		beforeMap, _ := r.Payload.Before.(opencdc.StructuredData)
+		if old := beforeMap[ec.ParentField]; old != nil && !sameID(old, afterMap[ec.ParentField]) {
+			previous[i] = old
+		}
+	}
+	return previous
+}
+
+// sameID reports whether a and b identify the same record, either by their ids
+// or as record links.
+func sameID(a, b interface{}) bool {
+	unwrap := func(v interface{}) interface{} {
+		switch id := v.(type) {
+		case models.RecordID:
+			return id.ID
+		case *models.RecordID:
+			if id != nil {
+				return id.ID
+			}
+		}
+		return v
+	}
+	return fmt.Sprint(unwrap(a)) == fmt.Sprint(unwrap(b))
+}
+
+// embedStatements appends the statements that keep the children in payloads
+// in sync with the array field of their parents to query, and adds the
+// variables they use to vars. A child is identified in the array by its id, so
+// creates and updates replace it and deletes remove it. Deleted children
+// without a known parent are removed from every parent holding them. previous
+// holds the parents updated children moved away from, as returned by movedFrom,
+// which they are removed from.
+//
+// If versionField is set, the children are written in the same query, and only
+// the ones that weren't skipped as stale are synced: created and updated
+// children whose stored version is their own, and deleted children that are
+// gone.
+func embedStatements(query *strings.Builder, vars map[string]interface{}, tableName string, ec *EmbedConfig, versionField string, operation opencdc.Operation, payloads []opencdc.StructuredData, previous []interface{}) error {
+	vars["embed_parent_tb"] = ec.Parent
+	vars["embed_child_tb"] = tableName
+	for i, payloadMap := range payloads {
+		vars[fmt.Sprintf("embed_parent%d", i)] = payloadMap[ec.ParentField]
+		vars[fmt.Sprintf("embed_id%d", i)] = payloadMap["id"]
+
+		element := fmt.Sprintf("$embed_data%d", i)
+		other := fmt.Sprintf("id != $embed_id%d", i)
+		contains := fmt.Sprintf("$embed_id%d IN %s.id", i, ec.Field)
+		if ec.Link {
+			element = fmt.Sprintf("type::thing($embed_child_tb, $embed_id%d)", i)
+			other = "$this != " + element
+			contains = fmt.Sprintf("%s CONTAINS %s", ec.Field, element)
+		} else {
+			vars[fmt.Sprintf("embed_data%d", i)] = payloadMap
+		}
+
+		var applied string
+		if versionField != "" {
+			if operation == opencdc.OperationDelete {
+				applied = fmt.Sprintf("!record::exists(type::thing($embed_child_tb, $embed_id%d))", i)
+			} else {
+				vars[fmt.Sprintf("embed_version%d", i)] = payloadMap[versionField]
+				applied = fmt.Sprintf("(SELECT VALUE %[1]s FROM ONLY type::thing($embed_child_tb, $embed_id%[2]d)) = $embed_version%[2]d", versionField, i)
+			}
+		}
+		if i < len(previous) && previous[i] != nil {
+			vars[fmt.Sprintf("embed_previous%d", i)] = previous[i]
+			fmt.Fprintf(query, "UPDATE type::thing($embed_parent_tb, $embed_previous%[1]d) SET %[2]s = (%[2]s ?? [])[WHERE %[3]s]%[4]s;\n", i, ec.Field, other, where(applied))
+		}
+
+		switch operation {
+		case opencdc.OperationSnapshot, opencdc.OperationCreate, opencdc.OperationUpdate:
+			fmt.Fprintf(query, "UPDATE type::thing($embed_parent_tb, $embed_parent%[1]d) SET %[2]s = array::append((%[2]s ?? [])[WHERE %[3]s], %[4]s)%[5]s;\n", i, ec.Field, other, element, where(applied))
+		case opencdc.OperationDelete:
+			if payloadMap[ec.ParentField] == nil {
+				fmt.Fprintf(query, "UPDATE type::table($embed_parent_tb) SET %[1]s = %[1]s[WHERE %[2]s]%[3]s;\n", ec.Field, other, where(contains, applied))
+			} else {
+				fmt.Fprintf(query, "UPDATE type::thing($embed_parent_tb, $embed_parent%[1]d) SET %[2]s = (%[2]s ?? [])[WHERE %[3]s]%[4]s;\n", i, ec.Field, other, where(applied))
+			}
+		default:
+			return fmt.Errorf("invalid operation %q", operation)
+		}
+	}
+	return nil
+}
+
+// where returns a WHERE clause that requires all of the conditions that aren't
+// empty, or nothing if there are none.
+func where(conditions ...string) string {
+	conditions = slices.DeleteFunc(conditions, func(c string) bool { return c == "" })
+	if len(conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conditions, " AND ")
+}
+
+// embeddedWrite writes a run of children to their table like writeRun, and
+// then syncs the ones that were written into their parents. Children skipped
+// as stale by VersionField aren't synced, as their parents may hold a newer
+// version already.
+func (d *Destination) embeddedWrite(ctx context.Context, t *target, tableName string, ec *EmbedConfig, operation opencdc.Operation, payloads []*opencdc.Data, rs []*opencdc.Record) ([]bool, error) {
+	// writing may strip the id from the payloads, so keep a copy
+	children := make([]opencdc.StructuredData, len(payloads))
+	for i, payload := range payloads {
+		payloadMap, ok := (*payload).(opencdc.StructuredData)
+		if !ok {
+			return make([]bool, len(payloads)), fmt.Errorf("unexpected type for payload: %T", *payload)
+		}
+		children[i] = maps.Clone(payloadMap)
+	}
+	previous := movedFrom(ec, operation, rs)
+
+	var landed, applied []bool
+	var err error
+	if d.config.VersionField != "" {
+		landed, applied, err = d.versionedWrite(ctx, t, tableName, operation, payloads)
+	} else {
+		landed, err = d.writeChanges(ctx, t, tableName, operation, payloads)
+		applied = landed
+	}
+
+	var written []opencdc.StructuredData
+	var moved []interface{}
+	for i, ok := range applied {
+		if ok {
+			written = append(written, children[i])
+			moved = append(moved, previous[i])
+		}
+	}
+	if len(written) == 0 {
+		return landed, err
+	}
+
+	var query strings.Builder
+	vars := make(map[string]interface{})
+	if embedErr := embedStatements(&query, vars, tableName, ec, "", operation, written, moved); embedErr != nil {
+		return landed, embedErr
+	}
+	results, queryErr := surrealdb.Query[interface{}](t.db, query.String(), vars)
+	if queryErr != nil {
+		sdk.Logger(ctx).Error().Msg("Failed to embed records: " + queryErr.Error())
+		return landed, fmt.Errorf("failed to embed records into %s: %w", ec.Parent, queryErr)
+	}
+	for _, result := range *results {
+		if result.Status != "OK" {
+			return landed, fmt.Errorf("failed to embed records into %s: %w", ec.Parent, statementError{result.Result})
+		}
+	}
+	return landed, err
+}
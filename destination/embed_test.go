@@ -0,0 +1,84 @@
+package destination
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/conduitio/conduit-commons/opencdc"
+	"github.com/matryer/is"
+	"github.com/surrealdb/surrealdb.go/pkg/models"
+)
+
+func TestEmbedStatements(t *testing.T) {
+	is := is.New(t)
+
+	ec := &EmbedConfig{Parent: "wp_posts", ParentField: "comment_post_ID"}
+	is.NoErr(ec.validate("wp_comments"))
+	is.Equal(ec.Field, "wp_comments")
+
+	var query strings.Builder
+	vars := make(map[string]interface{})
+	child := opencdc.StructuredData{"id": 3, "comment_post_ID": 5, "comment_content": "hi"}
+	is.NoErr(embedStatements(&query, vars, "wp_comments", ec, "", opencdc.OperationUpdate, []opencdc.StructuredData{child}, nil))
+	is.Equal(query.String(), "UPDATE type::thing($embed_parent_tb, $embed_parent0) SET wp_comments = array::append((wp_comments ?? [])[WHERE id != $embed_id0], $embed_data0);\n")
+	is.Equal(vars["embed_data0"], child)
+
+	ec.Link = true
+	query.Reset()
+	is.NoErr(embedStatements(&query, vars, "wp_comments", ec, "", opencdc.OperationDelete, []opencdc.StructuredData{{"id": 3}}, nil))
+	is.Equal(query.String(), "UPDATE type::table($embed_parent_tb) SET wp_comments = wp_comments[WHERE $this != type::thing($embed_child_tb, $embed_id0)] WHERE wp_comments CONTAINS type::thing($embed_child_tb, $embed_id0);\n")
+
+	// in a transaction with versioned writes, only children that were applied
+	// are synced
+	ec.Link = false
+	query.Reset()
+	vars = make(map[string]interface{})
+	child["updated_at"] = 7
+	is.NoErr(embedStatements(&query, vars, "wp_comments", ec, "updated_at", opencdc.OperationUpdate, []opencdc.StructuredData{child}, nil))
+	is.Equal(query.String(), "UPDATE type::thing($embed_parent_tb, $embed_parent0) SET wp_comments = array::append((wp_comments ?? [])[WHERE id != $embed_id0], $embed_data0) WHERE (SELECT VALUE updated_at FROM ONLY type::thing($embed_child_tb, $embed_id0)) = $embed_version0;\n")
+	is.Equal(vars["embed_version0"], 7)
+
+	query.Reset()
+	is.NoErr(embedStatements(&query, vars, "wp_comments", ec, "updated_at", opencdc.OperationDelete, []opencdc.StructuredData{{"id": 3}}, nil))
+	is.Equal(query.String(), "UPDATE type::table($embed_parent_tb) SET wp_comments = wp_comments[WHERE id != $embed_id0] WHERE $embed_id0 IN wp_comments.id AND !record::exists(type::thing($embed_child_tb, $embed_id0));\n")
+
+	// a child that moved to another parent is removed from the old one first
+	query.Reset()
+	vars = make(map[string]interface{})
+	delete(child, "updated_at")
+	is.NoErr(embedStatements(&query, vars, "wp_comments", ec, "", opencdc.OperationUpdate, []opencdc.StructuredData{child}, []interface{}{4}))
+	is.Equal(query.String(), "UPDATE type::thing($embed_parent_tb, $embed_previous0) SET wp_comments = (wp_comments ?? [])[WHERE id != $embed_id0];\n"+
+		"UPDATE type::thing($embed_parent_tb, $embed_parent0) SET wp_comments = array::append((wp_comments ?? [])[WHERE id != $embed_id0], $embed_data0);\n")
+	is.Equal(vars["embed_previous0"], 4)
+}
+
+func TestMovedFrom(t *testing.T) {
+	is := is.New(t)
+	d := &Destination{}
+	ec := &EmbedConfig{Parent: "wp_posts", ParentField: "comment_post_ID"}
+
+	moved := &opencdc.Record{
+		Operation: opencdc.OperationUpdate,
+		Payload: opencdc.Change{
+			Before: opencdc.RawData(`{"id": 3, "comment_post_ID": 4}`),
+			After:  opencdc.StructuredData{"id": 3, "comment_post_ID": 5},
+		},
+	}
+	is.NoErr(d.prepareEmbed(ec, moved))
+	stayed := &opencdc.Record{
+		Operation: opencdc.OperationUpdate,
+		Payload: opencdc.Change{
+			Before: opencdc.StructuredData{"id": 7, "comment_post_ID": 5},
+			After:  opencdc.StructuredData{"id": 7, "comment_post_ID": models.RecordID{Table: "wp_posts", ID: 5}},
+		},
+	}
+	is.NoErr(d.prepareEmbed(ec, stayed))
+
+	previous := movedFrom(ec, opencdc.OperationUpdate, []*opencdc.Record{moved, stayed})
+	is.Equal(len(previous), 2)
+	is.Equal(fmt.Sprint(previous[0]), "4")
+	is.Equal(previous[1], nil)
+
+	is.Equal(movedFrom(ec, opencdc.OperationCreate, []*opencdc.Record{moved}), []interface{}{nil})
+}
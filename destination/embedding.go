@@ -0,0 +1,196 @@
+package destination
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/conduitio/conduit-commons/opencdc"
+)
+
+// Vector index types.
+const (
+	vectorIndexHNSW  = "hnsw"
+	vectorIndexMTree = "mtree"
+)
+
+// vectorDistances lists the distance metrics supported by each index type.
+var vectorDistances = map[string][]string{
+	vectorIndexHNSW:  {"euclidean", "cosine", "manhattan", "minkowski", "chebyshev", "hamming", "jaccard", "pearson"},
+	vectorIndexMTree: {"euclidean", "cosine", "manhattan", "minkowski"},
+}
+
+// EmbeddingConfig describes a vector embedding field of a table.
+type EmbeddingConfig struct {
+	// Dimension is the number of values in the embedding. Records with a
+	// different number of values are rejected.
+	Dimension int `yaml:"dimension"`
+	// Distance is the distance metric of the index, "cosine" by default.
+	Distance string `yaml:"distance"`
+	// Order is the order of the "minkowski" distance, which requires it.
+	Order int `yaml:"order"`
+	// Index is the type of the vector index, "hnsw" or "mtree". If empty, the
+	// field is defined without an index.
+	Index string `yaml:"index"`
+	// Encoding is the type of the values in base64 encoded blobs, "float32"
+	// (default) or "float64", both little endian.
+	Encoding string `yaml:"encoding"`
+}
+
+// validate checks the embedding settings and fills in the defaults.
+func (ec *EmbeddingConfig) validate() error {
+	if ec.Dimension <= 0 {
+		return fmt.Errorf("dimension must be greater than 0")
+	}
+	if ec.Distance == "" {
+		ec.Distance = "cosine"
+	}
+	if ec.Encoding == "" {
+		ec.Encoding = "float32"
+	}
+	if ec.Encoding != "float32" && ec.Encoding != "float64" {
+		return fmt.Errorf("unknown encoding %q", ec.Encoding)
+	}
+	if ec.Index == "" {
+		return nil
+	}
+	distances, ok := vectorDistances[ec.Index]
+	if !ok {
+		return fmt.Errorf("unknown index %q", ec.Index)
+	}
+	if !slices.Contains(distances, ec.Distance) {
+		return fmt.Errorf("distance %q is not supported by %s indexes", ec.Distance, ec.Index)
+	}
+	if ec.Distance == "minkowski" && ec.Order <= 0 {
+		return fmt.Errorf("distance minkowski requires an order greater than 0")
+	}
+	return nil
+}
+
+// distance returns the distance metric of the index as used in its definition.
+func (ec EmbeddingConfig) distance() string {
+	if ec.Distance == "minkowski" {
+		return fmt.Sprintf("MINKOWSKI %d", ec.Order)
+	}
+	return strings.ToUpper(ec.Distance)
+}
+
+// surrealType returns the SurrealDB type of the embedding field.
+func (ec EmbeddingConfig) surrealType() string {
+	return fmt.Sprintf("option<array<float, %d>>", ec.Dimension)
+}
+
+// defineEmbeddingFields validates the embedding fields of all tables and
+// defines them, together with their vector indexes.
+func (d *Destination) defineEmbeddingFields(t *target) error {
+	var query strings.Builder
+	for tableName, tc := range d.tables {
+		for field, ec := range tc.Embeddings {
+			if err := ec.validate(); err != nil {
+				return fmt.Errorf("invalid embedding field %s of table %s: %w", field, tableName, err)
+			}
+			tc.Embeddings[field] = ec
+
+			fmt.Fprintf(&query, "DEFINE FIELD IF NOT EXISTS %s ON TABLE %s TYPE %s;\n", escapeIdent(field), escapeIdent(tableName), ec.surrealType())
+			if ec.Index != "" {
+				fmt.Fprintf(&query, "DEFINE INDEX IF NOT EXISTS %s ON TABLE %s FIELDS %s %s DIMENSION %d DIST %s;\n",
+					escapeIdent(tableName+"_"+field+"_"+ec.Index), escapeIdent(tableName), escapeIdent(field), strings.ToUpper(ec.Index), ec.Dimension, ec.distance())
+			}
+		}
+	}
+	if query.Len() == 0 {
+		return nil
+	}
+	if err := t.query(query.String()); err != nil {
+		return fmt.Errorf("failed to define embedding fields: %w", err)
+	}
+	return nil
+}
+
+// applyEmbeddings converts the embedding fields of a table in payloadMap into
+// float arrays and checks their dimension.
+func (d *Destination) applyEmbeddings(tableName string, payloadMap opencdc.StructuredData) error {
+	for field, ec := range d.tableConfig(tableName).Embeddings {
+		value, ok := payloadMap[field]
+		if !ok || value == nil {
+			continue
+		}
+		vector, err := toVector(value, ec.Encoding)
+		if err != nil {
+			return fmt.Errorf("invalid embedding field %s: %w", field, err)
+		}
+		if len(vector) != ec.Dimension {
+			return fmt.Errorf("embedding field %s has %d dimensions, expected %d", field, len(vector), ec.Dimension)
+		}
+		payloadMap[field] = vector
+	}
+	return nil
+}
+
+// toVector converts arrays of numbers, JSON arrays and base64 encoded or raw
+// blobs of little endian floats into a float array.
+func toVector(value interface{}, encoding string) ([]float64, error) {
+	switch v := value.(type) {
+	case []float64:
+		return v, nil
+	case []float32:
+		vector := make([]float64, len(v))
+		for i, f := range v {
+			vector[i] = float64(f)
+		}
+		return vector, nil
+	case []interface{}:
+		vector := make([]float64, len(v))
+		for i, item := range v {
+			f, err := toFloat(item)
+			if err != nil {
+				return nil, fmt.Errorf("item %d: %w", i, err)
+			}
+			vector[i] = f
+		}
+		return vector, nil
+	case []byte:
+		return vectorFromBlob(v, encoding)
+	case string:
+		if strings.HasPrefix(strings.TrimSpace(v), "[") {
+			var vector []float64
+			if err := json.Unmarshal([]byte(v), &vector); err != nil {
+				return nil, err
+			}
+			return vector, nil
+		}
+		blob, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64: %w", err)
+		}
+		return vectorFromBlob(blob, encoding)
+	default:
+		return nil, fmt.Errorf("unsupported embedding value of type %T", value)
+	}
+}
+
+// vectorFromBlob decodes a blob of little endian float32 or float64 values.
+func vectorFromBlob(blob []byte, encoding string) ([]float64, error) {
+	size := 4
+	if encoding == "float64" {
+		size = 8
+	}
+	if len(blob)%size != 0 {
+		return nil, fmt.Errorf("blob of %d bytes is not a multiple of %d", len(blob), size)
+	}
+
+	vector := make([]float64, len(blob)/size)
+	for i := range vector {
+		chunk := blob[i*size : (i+1)*size]
+		if size == 4 {
+			vector[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(chunk)))
+		} else {
+			vector[i] = math.Float64frombits(binary.LittleEndian.Uint64(chunk))
+		}
+	}
+	return vector, nil
+}
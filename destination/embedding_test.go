@@ -0,0 +1,70 @@
+package destination
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/conduitio/conduit-commons/opencdc"
+	"github.com/matryer/is"
+)
+
+func TestToVector(t *testing.T) {
+	is := is.New(t)
+
+	got, err := toVector([]interface{}{int64(1), 0.5}, "float32")
+	is.NoErr(err)
+	is.Equal(got, []float64{1, 0.5})
+
+	got, err = toVector("[0.25, -1]", "float32")
+	is.NoErr(err)
+	is.Equal(got, []float64{0.25, -1})
+
+	blob := make([]byte, 8)
+	binary.LittleEndian.PutUint32(blob, math.Float32bits(0.5))
+	binary.LittleEndian.PutUint32(blob[4:], math.Float32bits(-2))
+	got, err = toVector(base64.StdEncoding.EncodeToString(blob), "float32")
+	is.NoErr(err)
+	is.Equal(got, []float64{0.5, -2})
+
+	_, err = toVector(blob[:3], "float32")
+	is.True(err != nil)
+}
+
+func TestApplyEmbeddings(t *testing.T) {
+	is := is.New(t)
+
+	d := &Destination{tables: map[string]TableConfig{
+		"docs": {Embeddings: map[string]EmbeddingConfig{"embedding": {Dimension: 2}}},
+	}}
+
+	payload := opencdc.StructuredData{"embedding": []interface{}{1.0, 2.0}}
+	is.NoErr(d.applyEmbeddings("docs", payload))
+	is.Equal(payload["embedding"], []float64{1, 2})
+
+	payload = opencdc.StructuredData{"embedding": []interface{}{1.0, 2.0, 3.0}}
+	is.True(d.applyEmbeddings("docs", payload) != nil)
+}
+
+func TestEmbeddingConfigValidate(t *testing.T) {
+	is := is.New(t)
+
+	ec := EmbeddingConfig{Dimension: 3, Index: vectorIndexHNSW}
+	is.NoErr(ec.validate())
+	is.Equal(ec.Distance, "cosine")
+
+	ec = EmbeddingConfig{Dimension: 3, Index: vectorIndexMTree, Distance: "jaccard"}
+	is.True(ec.validate() != nil)
+
+	ec = EmbeddingConfig{Index: vectorIndexHNSW}
+	is.True(ec.validate() != nil)
+
+	// minkowski distances need an order
+	ec = EmbeddingConfig{Dimension: 3, Index: vectorIndexMTree, Distance: "minkowski"}
+	is.True(ec.validate() != nil)
+	ec.Order = 3
+	is.NoErr(ec.validate())
+	is.Equal(ec.distance(), "MINKOWSKI 3")
+	is.Equal(EmbeddingConfig{Distance: "cosine"}.distance(), "COSINE")
+}
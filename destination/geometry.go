@@ -0,0 +1,403 @@
+package destination
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/conduitio/conduit-commons/opencdc"
+	"github.com/surrealdb/surrealdb.go/pkg/models"
+)
+
+// Formats geometry fields can be built from.
+const (
+	geometryFormatGeoJSON = "geojson"
+	geometryFormatWKT     = "wkt"
+	geometryFormatLatLng  = "latlng"
+)
+
+// geometryTypes maps GeoJSON and WKT type names to SurrealDB geometry types.
+var geometryTypes = map[string]string{
+	"point":           "point",
+	"linestring":      "line",
+	"polygon":         "polygon",
+	"multipoint":      "multipoint",
+	"multilinestring": "multiline",
+	"multipolygon":    "multipolygon",
+}
+
+// GeometryConfig describes how a geometry field of a table is built.
+type GeometryConfig struct {
+	// Format of the source value: "geojson", "wkt" or "latlng".
+	Format string `yaml:"format"`
+	// Field holds the GeoJSON or WKT value. Defaults to the geometry field.
+	Field string `yaml:"field"`
+	// Lat and Lng hold the coordinates for the "latlng" format.
+	Lat string `yaml:"lat"`
+	Lng string `yaml:"lng"`
+	// Type is the SurrealDB geometry type, e.g. "point" or "polygon". If set,
+	// the field is defined with that type in Open.
+	Type string `yaml:"type"`
+	// DropSource removes the source fields once the geometry is built.
+	DropSource bool `yaml:"dropSource"`
+}
+
+// validate checks that the geometry field has what its format needs.
+func (gc GeometryConfig) validate() error {
+	switch gc.Format {
+	case geometryFormatGeoJSON, geometryFormatWKT:
+		return nil
+	case geometryFormatLatLng:
+		if gc.Lat == "" || gc.Lng == "" {
+			return fmt.Errorf("format %q requires lat and lng", gc.Format)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown format %q", gc.Format)
+	}
+}
+
+// droppedSources returns the source fields of the geometry field that are
+// removed once the geometry is built.
+func (gc GeometryConfig) droppedSources(field string) []string {
+	switch {
+	case !gc.DropSource:
+		return nil
+	case gc.Format == geometryFormatLatLng:
+		return []string{gc.Lat, gc.Lng}
+	case gc.Field != "" && gc.Field != field:
+		return []string{gc.Field}
+	default:
+		return nil
+	}
+}
+
+// dropGeometrySources removes the fields that geometry fields of a table drop
+// from fields, as records never hold them.
+func (d *Destination) dropGeometrySources(tableName string, fields []schemaField) []schemaField {
+	var dropped []string
+	for field, gc := range d.tableConfig(tableName).Geometry {
+		dropped = append(dropped, gc.droppedSources(field)...)
+	}
+	if len(dropped) == 0 {
+		return fields
+	}
+	return slices.DeleteFunc(slices.Clone(fields), func(field schemaField) bool {
+		return slices.Contains(dropped, field.Name)
+	})
+}
+
+// surrealType returns the SurrealDB type of the geometry field.
+func (gc GeometryConfig) surrealType() string {
+	if gc.Type == "" {
+		return "option<geometry>"
+	}
+	return "option<geometry<" + gc.Type + ">>"
+}
+
+// defineGeometryFields validates the geometry fields of all tables and defines
+// the ones with a type, so that geospatial queries work on them.
+func (d *Destination) defineGeometryFields(t *target) error {
+	var query strings.Builder
+	for tableName, tc := range d.tables {
+		for field, gc := range tc.Geometry {
+			if err := gc.validate(); err != nil {
+				return fmt.Errorf("invalid geometry field %s of table %s: %w", field, tableName, err)
+			}
+			if gc.Type != "" {
+				fmt.Fprintf(&query, "DEFINE FIELD IF NOT EXISTS %s ON TABLE %s TYPE %s;\n", escapeIdent(field), escapeIdent(tableName), gc.surrealType())
+			}
+		}
+	}
+	if query.Len() == 0 {
+		return nil
+	}
+	if err := t.query(query.String()); err != nil {
+		return fmt.Errorf("failed to define geometry fields: %w", err)
+	}
+	return nil
+}
+
+// applyGeometry builds the geometry fields of a table in payloadMap from their
+// source fields. Records without the source fields are left alone.
+func (d *Destination) applyGeometry(tableName string, payloadMap opencdc.StructuredData) error {
+	for field, gc := range d.tableConfig(tableName).Geometry {
+		var geometry interface{}
+		var err error
+		switch gc.Format {
+		case geometryFormatLatLng:
+			lat, latOK := payloadMap[gc.Lat]
+			lng, lngOK := payloadMap[gc.Lng]
+			if !latOK || !lngOK || lat == nil || lng == nil {
+				continue
+			}
+			geometry, err = latLngPoint(lat, lng)
+			if gc.DropSource {
+				delete(payloadMap, gc.Lat)
+				delete(payloadMap, gc.Lng)
+			}
+		default:
+			source := gc.Field
+			if source == "" {
+				source = field
+			}
+			value, ok := payloadMap[source]
+			if !ok || value == nil {
+				continue
+			}
+			if gc.Format == geometryFormatWKT {
+				geometry, err = geometryFromWKT(value)
+			} else {
+				geometry, err = geometryFromGeoJSON(value)
+			}
+			if gc.DropSource && source != field {
+				delete(payloadMap, source)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("failed to build geometry field %s: %w", field, err)
+		}
+		payloadMap[field] = geometry
+	}
+	return nil
+}
+
+// geometryPoint returns the point at the given longitude and latitude.
+// surrealdb.go encodes the fields of GeometryPoint in the order they are
+// declared, while SurrealDB reads points as (longitude, latitude), so the
+// longitude goes into the field named Latitude.
+func geometryPoint(lng, lat float64) models.GeometryPoint {
+	return models.GeometryPoint{Latitude: lng, Longitude: lat}
+}
+
+// latLngPoint builds a point from numeric or string coordinates.
+func latLngPoint(lat, lng interface{}) (models.GeometryPoint, error) {
+	latF, err := toCoordinate(lat)
+	if err != nil {
+		return models.GeometryPoint{}, fmt.Errorf("invalid latitude: %w", err)
+	}
+	lngF, err := toCoordinate(lng)
+	if err != nil {
+		return models.GeometryPoint{}, fmt.Errorf("invalid longitude: %w", err)
+	}
+	return geometryPoint(lngF, latF), nil
+}
+
+// toCoordinate converts a numeric or string coordinate to a float64.
+func toCoordinate(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case string:
+		return strconv.ParseFloat(strings.TrimSpace(v), 64)
+	case models.DecimalString:
+		return strconv.ParseFloat(string(v), 64)
+	default:
+		return toFloat(value)
+	}
+}
+
+// geometryFromGeoJSON builds a geometry from a GeoJSON geometry object, given
+// as a map or as a JSON string.
+func geometryFromGeoJSON(value interface{}) (interface{}, error) {
+	var obj map[string]interface{}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		obj = v
+	case opencdc.StructuredData:
+		obj = v
+	case string:
+		if err := json.Unmarshal([]byte(v), &obj); err != nil {
+			return nil, fmt.Errorf("invalid GeoJSON: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported GeoJSON value of type %T", value)
+	}
+
+	typ, _ := obj["type"].(string)
+	return buildGeometry(typ, obj["coordinates"])
+}
+
+// buildGeometry builds the SurrealDB geometry of the GeoJSON or WKT type typ
+// from its coordinates, nested the way GeoJSON nests them.
+func buildGeometry(typ string, coordinates interface{}) (interface{}, error) {
+	switch geometryTypes[strings.ToLower(typ)] {
+	case "point":
+		return pointFromCoordinates(coordinates)
+	case "line":
+		return lineFromCoordinates(coordinates)
+	case "polygon":
+		return polygonFromCoordinates(coordinates)
+	case "multipoint":
+		line, err := lineFromCoordinates(coordinates)
+		return models.GeometryMultiPoint(line), err
+	case "multiline":
+		items, err := coordinateList(coordinates)
+		if err != nil {
+			return nil, err
+		}
+		multi := make(models.GeometryMultiLine, len(items))
+		for i, item := range items {
+			if multi[i], err = lineFromCoordinates(item); err != nil {
+				return nil, err
+			}
+		}
+		return multi, nil
+	case "multipolygon":
+		items, err := coordinateList(coordinates)
+		if err != nil {
+			return nil, err
+		}
+		multi := make(models.GeometryMultiPolygon, len(items))
+		for i, item := range items {
+			if multi[i], err = polygonFromCoordinates(item); err != nil {
+				return nil, err
+			}
+		}
+		return multi, nil
+	default:
+		return nil, fmt.Errorf("unsupported geometry type %q", typ)
+	}
+}
+
+func pointFromCoordinates(coordinates interface{}) (models.GeometryPoint, error) {
+	items, err := coordinateList(coordinates)
+	if err != nil {
+		return models.GeometryPoint{}, err
+	}
+	if len(items) < 2 {
+		return models.GeometryPoint{}, fmt.Errorf("point needs 2 coordinates, got %d", len(items))
+	}
+	x, err := toCoordinate(items[0])
+	if err != nil {
+		return models.GeometryPoint{}, err
+	}
+	y, err := toCoordinate(items[1])
+	if err != nil {
+		return models.GeometryPoint{}, err
+	}
+	return geometryPoint(x, y), nil
+}
+
+func lineFromCoordinates(coordinates interface{}) (models.GeometryLine, error) {
+	items, err := coordinateList(coordinates)
+	if err != nil {
+		return nil, err
+	}
+	line := make(models.GeometryLine, len(items))
+	for i, item := range items {
+		if line[i], err = pointFromCoordinates(item); err != nil {
+			return nil, err
+		}
+	}
+	return line, nil
+}
+
+func polygonFromCoordinates(coordinates interface{}) (models.GeometryPolygon, error) {
+	items, err := coordinateList(coordinates)
+	if err != nil {
+		return nil, err
+	}
+	polygon := make(models.GeometryPolygon, len(items))
+	for i, item := range items {
+		if polygon[i], err = lineFromCoordinates(item); err != nil {
+			return nil, err
+		}
+	}
+	return polygon, nil
+}
+
+func coordinateList(coordinates interface{}) ([]interface{}, error) {
+	items, ok := coordinates.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid coordinates of type %T", coordinates)
+	}
+	return items, nil
+}
+
+// geometryFromWKT builds a geometry from Well-Known Text, e.g.
+// "POINT (30 10)" or "POLYGON ((30 10, 40 40, 20 40, 30 10))".
+func geometryFromWKT(value interface{}) (interface{}, error) {
+	wkt, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("unsupported WKT value of type %T", value)
+	}
+	wkt = strings.TrimSpace(wkt)
+	open := strings.IndexByte(wkt, '(')
+	if open < 0 {
+		return nil, fmt.Errorf("invalid WKT %q", wkt)
+	}
+	typ := strings.TrimSpace(wkt[:open])
+
+	coordinates, rest, err := parseWKTList(wkt[open:])
+	if err != nil {
+		return nil, fmt.Errorf("invalid WKT %q: %w", wkt, err)
+	}
+	if strings.TrimSpace(rest) != "" {
+		return nil, fmt.Errorf("invalid WKT %q: unexpected %q", wkt, rest)
+	}
+	// a point is a list holding a single coordinate
+	if strings.EqualFold(typ, "point") && len(coordinates) == 1 {
+		return buildGeometry(typ, coordinates[0])
+	}
+	// multipoints may wrap each point in parentheses
+	if strings.EqualFold(typ, "multipoint") {
+		for i, item := range coordinates {
+			if wrapped, ok := item.([]interface{}); ok && len(wrapped) == 1 {
+				if _, ok := wrapped[0].([]interface{}); ok {
+					coordinates[i] = wrapped[0]
+				}
+			}
+		}
+	}
+	return buildGeometry(typ, coordinates)
+}
+
+// parseWKTList parses a parenthesized, comma separated WKT list, where every
+// item is either a nested list or a coordinate of space separated numbers,
+// into the nesting GeoJSON uses. It returns the text after the list.
+func parseWKTList(s string) ([]interface{}, string, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "(") {
+		return nil, s, fmt.Errorf("expected '('")
+	}
+	s = s[1:]
+
+	var items []interface{}
+	for {
+		s = strings.TrimSpace(s)
+		if strings.HasPrefix(s, "(") {
+			nested, rest, err := parseWKTList(s)
+			if err != nil {
+				return nil, s, err
+			}
+			items = append(items, nested)
+			s = rest
+		} else {
+			end := strings.IndexAny(s, ",)")
+			if end < 0 {
+				return nil, s, fmt.Errorf("expected ')'")
+			}
+			var coordinate []interface{}
+			for _, field := range strings.Fields(s[:end]) {
+				f, err := strconv.ParseFloat(field, 64)
+				if err != nil {
+					return nil, s, err
+				}
+				coordinate = append(coordinate, f)
+			}
+			items = append(items, coordinate)
+			s = s[end:]
+		}
+
+		s = strings.TrimSpace(s)
+		switch {
+		case strings.HasPrefix(s, ","):
+			s = s[1:]
+		case strings.HasPrefix(s, ")"):
+			return items, s[1:], nil
+		default:
+			return nil, s, fmt.Errorf("expected ',' or ')'")
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package destination
+
+import (
+	"testing"
+
+	"github.com/conduitio/conduit-commons/opencdc"
+	"github.com/matryer/is"
+	"github.com/surrealdb/surrealdb.go/pkg/models"
+)
+
+func TestGeometryFromWKT(t *testing.T) {
+	is := is.New(t)
+
+	got, err := geometryFromWKT("POINT (30 10)")
+	is.NoErr(err)
+	is.Equal(got, geometryPoint(30, 10))
+
+	got, err = geometryFromWKT("POLYGON ((30 10, 40 40, 20 40, 30 10))")
+	is.NoErr(err)
+	is.Equal(got, models.GeometryPolygon{{
+		geometryPoint(30, 10), geometryPoint(40, 40), geometryPoint(20, 40), geometryPoint(30, 10),
+	}})
+
+	got, err = geometryFromWKT("MULTIPOINT ((10 40), (40 30))")
+	is.NoErr(err)
+	is.Equal(got, models.GeometryMultiPoint{geometryPoint(10, 40), geometryPoint(40, 30)})
+
+	_, err = geometryFromWKT("POINT (30 10")
+	is.True(err != nil)
+}
+
+func TestGeometryFromGeoJSON(t *testing.T) {
+	is := is.New(t)
+
+	got, err := geometryFromGeoJSON(`{"type": "LineString", "coordinates": [[30, 10], [10, 30]]}`)
+	is.NoErr(err)
+	is.Equal(got, models.GeometryLine{geometryPoint(30, 10), geometryPoint(10, 30)})
+
+	point, err := latLngPoint("51.5", -0.12)
+	is.NoErr(err)
+	is.Equal(point, geometryPoint(-0.12, 51.5))
+}
+
+func TestDesiredFieldsDropSource(t *testing.T) {
+	is := is.New(t)
+	d := &Destination{
+		config: Config{DefineSchema: true},
+		tables: map[string]TableConfig{
+			"wp_places": {Geometry: map[string]GeometryConfig{
+				"location": {Format: geometryFormatLatLng, Lat: "lat", Lng: "lng", Type: "point", DropSource: true},
+			}},
+		},
+	}
+
+	desired := d.desiredFields("wp_places", []schemaField{
+		{Name: "name", Type: "string"},
+		{Name: "lat", Type: "float"},
+		{Name: "lng", Type: "float"},
+	})
+	is.Equal(desired, []schemaField{
+		{Name: "name", Type: "string"},
+		{Name: "location", Type: "option<geometry<point>>"},
+	})
+
+	// records hold exactly the defined fields once the geometry is built
+	payload := opencdc.StructuredData{"name": "Office", "lat": 52.5, "lng": 13.4}
+	is.NoErr(d.applyGeometry("wp_places", payload))
+	for field := range payload {
+		is.True(hasField(desired, field))
+	}
+	is.Equal(len(payload), len(desired))
+}
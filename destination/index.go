@@ -0,0 +1,127 @@
+package destination
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AnalyzerConfig describes a full-text search analyzer, configured in the
+// relations schema file under "analyzers".
+type AnalyzerConfig struct {
+	Name string `yaml:"name"`
+	// Tokenizers split text into terms, e.g. "blank", "class" or "punct".
+	Tokenizers []string `yaml:"tokenizers"`
+	// Filters transform the terms, e.g. "lowercase", "ascii" or
+	// "snowball(english)".
+	Filters []string `yaml:"filters"`
+}
+
+// IndexConfig describes an index of a table.
+type IndexConfig struct {
+	// Name of the index, "<table>_<fields>_idx" by default.
+	Name string `yaml:"name"`
+	// Fields the index covers; more than one makes a composite index.
+	Fields []string `yaml:"fields"`
+	// Unique rejects records with values that are already indexed.
+	Unique bool `yaml:"unique"`
+	// Search makes the index a full-text search index of a single field.
+	Search *SearchConfig `yaml:"search"`
+}
+
+// SearchConfig holds the settings of a full-text search index.
+type SearchConfig struct {
+	// Analyzer is the name of the analyzer the field is indexed with.
+	Analyzer string `yaml:"analyzer"`
+	// BM25 enables relevance scoring with search::score.
+	BM25 bool `yaml:"bm25"`
+	// Highlights enables search::highlight and search::offsets.
+	Highlights bool `yaml:"highlights"`
+}
+
+// statement returns the statement that defines the analyzer.
+func (ac AnalyzerConfig) statement() (string, error) {
+	if ac.Name == "" {
+		return "", fmt.Errorf("analyzer without name")
+	}
+	if len(ac.Tokenizers) == 0 && len(ac.Filters) == 0 {
+		return "", fmt.Errorf("analyzer %s needs tokenizers or filters", ac.Name)
+	}
+
+	var stmt strings.Builder
+	fmt.Fprintf(&stmt, "DEFINE ANALYZER IF NOT EXISTS %s", escapeIdent(ac.Name))
+	if len(ac.Tokenizers) > 0 {
+		fmt.Fprintf(&stmt, " TOKENIZERS %s", strings.Join(ac.Tokenizers, ","))
+	}
+	if len(ac.Filters) > 0 {
+		fmt.Fprintf(&stmt, " FILTERS %s", strings.Join(ac.Filters, ","))
+	}
+	stmt.WriteString(";")
+	return stmt.String(), nil
+}
+
+// statement returns the statement that defines the index on the table.
+func (ic IndexConfig) statement(tableName string) (string, error) {
+	if len(ic.Fields) == 0 {
+		return "", fmt.Errorf("index without fields")
+	}
+	name := ic.Name
+	if name == "" {
+		name = tableName + "_" + strings.ReplaceAll(strings.Join(ic.Fields, "_"), ".", "_") + "_idx"
+	}
+
+	var stmt strings.Builder
+	fmt.Fprintf(&stmt, "DEFINE INDEX IF NOT EXISTS %s ON TABLE %s FIELDS %s", escapeIdent(name), escapeIdent(tableName), strings.Join(ic.Fields, ", "))
+	switch {
+	case ic.Search != nil:
+		if ic.Unique {
+			return "", fmt.Errorf("index %s can't be both unique and a search index", name)
+		}
+		if len(ic.Fields) != 1 {
+			return "", fmt.Errorf("search index %s must cover a single field", name)
+		}
+		if ic.Search.Analyzer == "" {
+			return "", fmt.Errorf("search index %s needs an analyzer", name)
+		}
+		fmt.Fprintf(&stmt, " SEARCH ANALYZER %s", escapeIdent(ic.Search.Analyzer))
+		if ic.Search.BM25 {
+			stmt.WriteString(" BM25")
+		}
+		if ic.Search.Highlights {
+			stmt.WriteString(" HIGHLIGHTS")
+		}
+	case ic.Unique:
+		stmt.WriteString(" UNIQUE")
+	}
+	stmt.WriteString(";")
+	return stmt.String(), nil
+}
+
+// defineIndexes defines the analyzers and the indexes of all tables. Both are
+// only defined if they don't exist yet, so changing the definition of an
+// existing index requires removing it first.
+func (d *Destination) defineIndexes(t *target) error {
+	var query strings.Builder
+	for _, ac := range d.analyzers {
+		stmt, err := ac.statement()
+		if err != nil {
+			return fmt.Errorf("invalid analyzer: %w", err)
+		}
+		query.WriteString(stmt + "\n")
+	}
+	for tableName, tc := range d.tables {
+		for _, ic := range tc.Indexes {
+			stmt, err := ic.statement(tableName)
+			if err != nil {
+				return fmt.Errorf("invalid index of table %s: %w", tableName, err)
+			}
+			query.WriteString(stmt + "\n")
+		}
+	}
+	if query.Len() == 0 {
+		return nil
+	}
+	if err := t.query(query.String()); err != nil {
+		return fmt.Errorf("failed to define indexes: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package destination
+
+import (
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestIndexStatement(t *testing.T) {
+	is := is.New(t)
+
+	stmt, err := IndexConfig{Fields: []string{"post_name", "post_type"}, Unique: true}.statement("wp_posts")
+	is.NoErr(err)
+	is.Equal(stmt, "DEFINE INDEX IF NOT EXISTS `wp_posts_post_name_post_type_idx` ON TABLE `wp_posts` FIELDS post_name, post_type UNIQUE;")
+
+	stmt, err = IndexConfig{
+		Name:   "content_search",
+		Fields: []string{"post_content"},
+		Search: &SearchConfig{Analyzer: "english", BM25: true, Highlights: true},
+	}.statement("wp_posts")
+	is.NoErr(err)
+	is.Equal(stmt, "DEFINE INDEX IF NOT EXISTS `content_search` ON TABLE `wp_posts` FIELDS post_content SEARCH ANALYZER `english` BM25 HIGHLIGHTS;")
+
+	// names that aren't plain identifiers are escaped
+	stmt, err = IndexConfig{Fields: []string{"post_name"}}.statement("wp-posts")
+	is.NoErr(err)
+	is.Equal(stmt, "DEFINE INDEX IF NOT EXISTS `wp-posts_post_name_idx` ON TABLE `wp-posts` FIELDS post_name;")
+
+	_, err = IndexConfig{Fields: []string{"a", "b"}, Search: &SearchConfig{Analyzer: "english"}}.statement("t")
+	is.True(err != nil)
+
+	stmt, err = AnalyzerConfig{Name: "english", Tokenizers: []string{"blank", "class"}, Filters: []string{"lowercase", "snowball(english)"}}.statement()
+	is.NoErr(err)
+	is.Equal(stmt, "DEFINE ANALYZER IF NOT EXISTS `english` TOKENIZERS blank,class FILTERS lowercase,snowball(english);")
+}
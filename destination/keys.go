@@ -0,0 +1,50 @@
+package destination
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/conduitio/conduit-commons/opencdc"
+)
+
+// normalizeKey turns a raw key, as sent by sources like Kafka, NATS or HTTP,
+// into a structured one. JSON objects are used as they are. Any other JSON
+// value, like a number, string or array, and plain text become the id of the
+// record.
+func (d *Destination) normalizeKey(r *opencdc.Record) error {
+	raw, ok := r.Key.(opencdc.RawData)
+	if !ok {
+		return nil
+	}
+	trimmed := bytes.TrimSpace(raw)
+	if len(trimmed) == 0 {
+		return fmt.Errorf("record has an empty key")
+	}
+
+	var value interface{}
+	dec := json.NewDecoder(bytes.NewReader(trimmed))
+	dec.UseNumber()
+	if err := dec.Decode(&value); err != nil || dec.More() {
+		if !utf8.Valid(trimmed) {
+			return fmt.Errorf("record has a binary key, which can't be used as id")
+		}
+		r.Key = opencdc.StructuredData{"id": string(trimmed)}
+		return nil
+	}
+	value, err := d.normalizeNumber(value)
+	if err != nil {
+		return fmt.Errorf("invalid key: %w", err)
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		r.Key = opencdc.StructuredData(v)
+	case nil:
+		return fmt.Errorf("record has a null key")
+	default:
+		r.Key = opencdc.StructuredData{"id": v}
+	}
+	return nil
+}
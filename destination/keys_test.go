@@ -0,0 +1,68 @@
+package destination
+
+import (
+	"context"
+	"testing"
+
+	"github.com/conduitio/conduit-commons/opencdc"
+	"github.com/matryer/is"
+)
+
+func TestNormalizeKey(t *testing.T) {
+	is := is.New(t)
+	d := &Destination{}
+
+	tests := []struct {
+		key  opencdc.Data
+		want opencdc.Data
+	}{
+		{opencdc.RawData(`{"user_id": 7}`), opencdc.StructuredData{"user_id": int64(7)}},
+		{opencdc.RawData("42"), opencdc.StructuredData{"id": int64(42)}},
+		{opencdc.RawData(`"abc"`), opencdc.StructuredData{"id": "abc"}},
+		{opencdc.RawData(`[1, "a"]`), opencdc.StructuredData{"id": []interface{}{int64(1), "a"}}},
+		{opencdc.RawData(" user-7\n"), opencdc.StructuredData{"id": "user-7"}},
+		{opencdc.StructuredData{"id": 1}, opencdc.StructuredData{"id": 1}},
+	}
+	for _, tt := range tests {
+		r := opencdc.Record{Key: tt.key}
+		is.NoErr(d.normalizeKey(&r))
+		is.Equal(r.Key, tt.want)
+	}
+
+	for _, key := range []opencdc.RawData{{}, opencdc.RawData("null"), {0xff, 0xfe}} {
+		r := opencdc.Record{Key: key}
+		is.True(d.normalizeKey(&r) != nil)
+	}
+}
+
+func TestProcessPayloadScalarKey(t *testing.T) {
+	is := is.New(t)
+	d := &Destination{config: Config{FieldNames: fieldNamesKeep, NullValues: nullsKeep}}
+
+	r := opencdc.Record{
+		Operation: opencdc.OperationCreate,
+		Key:       opencdc.RawData("user-7"),
+		Payload:   opencdc.Change{After: opencdc.StructuredData{"name": "Ann"}},
+	}
+	is.NoErr(d.processPayload(context.Background(), &r))
+	is.Equal(r.Payload.After, opencdc.StructuredData{"id": "user-7", "name": "Ann"})
+}
+
+func TestGetKeyColumnName(t *testing.T) {
+	is := is.New(t)
+	d := &Destination{}
+
+	column, err := d.getKeyColumnName(opencdc.StructuredData{"user_id": 7})
+	is.NoErr(err)
+	is.Equal(column, "user_id")
+
+	column, err = d.getKeyColumnName(opencdc.StructuredData{"id": 7, "tenant": "a"})
+	is.NoErr(err)
+	is.Equal(column, "id")
+
+	// raw JSON keys with several fields are rejected rather than picking one
+	r := opencdc.Record{Key: opencdc.RawData(`{"tenant": "a", "user_id": 7}`)}
+	is.NoErr(d.normalizeKey(&r))
+	_, err = d.getKeyColumnName(r.Key)
+	is.Equal(err.Error(), "composite keys are not supported, key has fields tenant, user_id")
+}
@@ -0,0 +1,229 @@
+package destination
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/conduitio/conduit-commons/opencdc"
+)
+
+// mappingFuncs are the functions available in table name templates.
+var mappingFuncs = template.FuncMap{
+	"lower":      strings.ToLower,
+	"upper":      strings.ToUpper,
+	"replace":    strings.ReplaceAll,
+	"trimPrefix": strings.TrimPrefix,
+	"trimSuffix": strings.TrimSuffix,
+}
+
+// TableMapping maps collection names to SurrealDB table names, configured in
+// the relations schema file under "tableNames". Table settings and relations
+// refer to the mapped names.
+type TableMapping struct {
+	// Prefix is put in front of every table name.
+	Prefix string `yaml:"prefix"`
+	// Rename maps collection names to table names. Renamed collections skip
+	// the rules.
+	Rename map[string]string `yaml:"rename"`
+	// Rules are tried in order, the first one matching the collection is used.
+	Rules []TableRule `yaml:"rules"`
+}
+
+// TableRule maps the collections matching a regular expression.
+type TableRule struct {
+	// Match is the regular expression the collection has to match. An empty
+	// expression matches every collection.
+	Match string `yaml:"match"`
+	// Table is a Go template of the table name. It is executed with
+	// .Collection, .Match holding the submatches and .Groups holding the named
+	// submatches, e.g. "{{.Groups.table}}".
+	Table string `yaml:"table"`
+	// Fields maps fields added to every record of the collection to Go
+	// templates of their values, executed like Table.
+	Fields map[string]string `yaml:"fields"`
+	// IDFields lists added fields whose values are put in front of the record
+	// id, making it an array id like posts:["2", 5], so that ids stay unique
+	// when several collections are mapped into the same table. Links,
+	// relations, meta and embed settings can't refer to such tables.
+	IDFields []string `yaml:"idFields"`
+
+	match  *regexp.Regexp
+	table  *template.Template
+	fields map[string]*template.Template
+}
+
+// mappedTable is the result of mapping a collection.
+type mappedTable struct {
+	Name     string
+	Fields   map[string]string
+	IDFields []string
+}
+
+// compile parses the expressions and templates of the rules.
+func (tm *TableMapping) compile() error {
+	for i := range tm.Rules {
+		rule := &tm.Rules[i]
+		var err error
+		if rule.match, err = regexp.Compile(rule.Match); err != nil {
+			return fmt.Errorf("invalid match of table rule %d: %w", i, err)
+		}
+		if rule.Table != "" {
+			if rule.table, err = template.New("table").Funcs(mappingFuncs).Parse(rule.Table); err != nil {
+				return fmt.Errorf("invalid table of table rule %d: %w", i, err)
+			}
+		}
+		for _, field := range rule.IDFields {
+			if _, ok := rule.Fields[field]; !ok {
+				return fmt.Errorf("id field %s of table rule %d is not one of its fields", field, i)
+			}
+		}
+		rule.fields = make(map[string]*template.Template, len(rule.Fields))
+		for field, text := range rule.Fields {
+			if rule.fields[field], err = template.New(field).Funcs(mappingFuncs).Parse(text); err != nil {
+				return fmt.Errorf("invalid field %s of table rule %d: %w", field, i, err)
+			}
+		}
+	}
+	return nil
+}
+
+// mapCollection returns the table name and added fields of a collection.
+func (tm *TableMapping) mapCollection(collection string) (mappedTable, error) {
+	mapped := mappedTable{Name: collection}
+	if renamed, ok := tm.Rename[collection]; ok {
+		mapped.Name = renamed
+	} else {
+		for _, rule := range tm.Rules {
+			match := rule.match.FindStringSubmatch(collection)
+			if match == nil {
+				continue
+			}
+			data := struct {
+				Collection string
+				Match      []string
+				Groups     map[string]string
+			}{Collection: collection, Match: match, Groups: make(map[string]string)}
+			for i, name := range rule.match.SubexpNames() {
+				if name != "" {
+					data.Groups[name] = match[i]
+				}
+			}
+
+			if rule.table != nil {
+				name, err := executeTemplate(rule.table, data)
+				if err != nil {
+					return mapped, fmt.Errorf("failed to map collection %s: %w", collection, err)
+				}
+				mapped.Name = name
+			}
+			mapped.Fields = make(map[string]string, len(rule.fields))
+			for field, tmpl := range rule.fields {
+				value, err := executeTemplate(tmpl, data)
+				if err != nil {
+					return mapped, fmt.Errorf("failed to map field %s of collection %s: %w", field, collection, err)
+				}
+				mapped.Fields[field] = value
+			}
+			mapped.IDFields = rule.IDFields
+			break
+		}
+	}
+	if mapped.Name == "" {
+		return mapped, fmt.Errorf("collection %s is mapped to an empty table name", collection)
+	}
+	mapped.Name = tm.Prefix + mapped.Name
+	return mapped, nil
+}
+
+func executeTemplate(tmpl *template.Template, data interface{}) (string, error) {
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out.String()), nil
+}
+
+// mapTable returns the mapping of the collection of r, which is computed once
+// per collection.
+func (d *Destination) mapTable(r opencdc.Record) (mappedTable, error) {
+	collection := r.Metadata["opencdc.collection"]
+	if collection == "" {
+		return mappedTable{}, nil
+	}
+	if mapped, ok := d.mappedTables[collection]; ok {
+		return mapped, nil
+	}
+	mapped, err := d.tableMapping.mapCollection(collection)
+	if err != nil {
+		return mappedTable{}, err
+	}
+	if len(mapped.IDFields) > 0 {
+		if err := d.checkArrayIDs(mapped.Name); err != nil {
+			return mappedTable{}, fmt.Errorf("collection %s: %w", collection, err)
+		}
+	}
+	d.mappedTables[collection] = mapped
+	return mapped, nil
+}
+
+// checkArrayIDs makes sure no settings refer to the records of a table with
+// id fields. Links, relations, meta rows and embedded children build ids from
+// a single foreign key, which never matches the array ids of such a table.
+func (d *Destination) checkArrayIDs(tableName string) error {
+	if len(d.tableConfig(tableName).Links) > 0 {
+		return fmt.Errorf("table %s has id fields and can't have links", tableName)
+	}
+	for name, tc := range d.tables {
+		for field, linked := range tc.Links {
+			if linked == tableName {
+				return fmt.Errorf("table %s has id fields, so link %s of table %s can't refer to it", tableName, field, name)
+			}
+		}
+		if (tc.Meta != nil && tc.Meta.Parent == tableName) || (tc.Embed != nil && tc.Embed.Parent == tableName) {
+			return fmt.Errorf("table %s has id fields, so it can't be the parent of table %s", tableName, name)
+		}
+	}
+	for _, rc := range d.relations {
+		if rc.Trigger.Table == tableName || rc.InTable == tableName || rc.OutTable == tableName {
+			return fmt.Errorf("table %s has id fields and can't be part of relation %s", tableName, rc.Name)
+		}
+	}
+	return nil
+}
+
+// applyMappedFields adds the fields of the table rule the collection of r
+// matched to its payload, and puts the id fields in front of its id.
+func (d *Destination) applyMappedFields(r *opencdc.Record, payloadMap opencdc.StructuredData) error {
+	mapped, err := d.mapTable(*r)
+	if err != nil {
+		return err
+	}
+	for field, value := range mapped.Fields {
+		payloadMap[field] = value
+	}
+	if id, ok := payloadMap["id"]; ok && len(mapped.IDFields) > 0 {
+		arrayID := make([]interface{}, 0, len(mapped.IDFields)+1)
+		for _, field := range mapped.IDFields {
+			arrayID = append(arrayID, mapped.Fields[field])
+		}
+		payloadMap["id"] = append(arrayID, id)
+	}
+	return nil
+}
+
+// mappedFields returns the fields table rules add to records of a table.
+func (d *Destination) mappedFields(tableName string) []string {
+	var fields []string
+	for _, mapped := range d.mappedTables {
+		if mapped.Name != tableName {
+			continue
+		}
+		for field := range mapped.Fields {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
@@ -0,0 +1,81 @@
+package destination
+
+import (
+	"testing"
+
+	"github.com/conduitio/conduit-commons/opencdc"
+	"github.com/matryer/is"
+)
+
+func TestMapCollection(t *testing.T) {
+	is := is.New(t)
+
+	tm := TableMapping{
+		Prefix: "wp_",
+		Rename: map[string]string{"wp_users": "people"},
+		Rules: []TableRule{{
+			Match:    `^wp_(?P<site>\d+)_(?P<table>.+)$`,
+			Table:    "{{.Groups.table}}",
+			Fields:   map[string]string{"site": "{{.Groups.site}}"},
+			IDFields: []string{"site"},
+		}, {
+			Match: `^wp_`,
+			Table: `{{trimPrefix .Collection "wp_"}}`,
+		}},
+	}
+	is.NoErr(tm.compile())
+
+	mapped, err := tm.mapCollection("wp_2_posts")
+	is.NoErr(err)
+	is.Equal(mapped, mappedTable{Name: "wp_posts", Fields: map[string]string{"site": "2"}, IDFields: []string{"site"}})
+
+	mapped, err = tm.mapCollection("wp_options")
+	is.NoErr(err)
+	is.Equal(mapped.Name, "wp_options")
+	is.Equal(len(mapped.Fields), 0)
+
+	mapped, err = tm.mapCollection("wp_users")
+	is.NoErr(err)
+	is.Equal(mapped.Name, "wp_people")
+
+	mapped, err = tm.mapCollection("orders")
+	is.NoErr(err)
+	is.Equal(mapped.Name, "wp_orders")
+}
+
+func TestMapTableArrayIDs(t *testing.T) {
+	is := is.New(t)
+
+	d := &Destination{
+		tableMapping: TableMapping{Rules: []TableRule{{
+			Match:    `^wp_(?P<site>\d+)_(?P<table>.+)$`,
+			Table:    "wp_{{.Groups.table}}",
+			Fields:   map[string]string{"site": "{{.Groups.site}}"},
+			IDFields: []string{"site"},
+		}}},
+		tables: map[string]TableConfig{
+			"wp_comments": {Links: map[string]string{"comment_post_ID": "wp_posts"}},
+		},
+		mappedTables: make(map[string]mappedTable),
+	}
+	is.NoErr(d.tableMapping.compile())
+
+	rec := func(collection string) opencdc.Record {
+		return opencdc.Record{Metadata: opencdc.Metadata{"opencdc.collection": collection}}
+	}
+
+	// links would point to wp_posts:5 rather than wp_posts:["2", 5]
+	_, err := d.mapTable(rec("wp_2_posts"))
+	is.True(err != nil)
+	_, err = d.mapTable(rec("wp_2_comments"))
+	is.True(err != nil)
+
+	mapped, err := d.mapTable(rec("wp_2_options"))
+	is.NoErr(err)
+	is.Equal(mapped.Name, "wp_options")
+
+	d.relations = []RelationEventConfig{{Name: "wrote", InTable: "wp_users", OutTable: "wp_options"}}
+	d.mappedTables = make(map[string]mappedTable)
+	_, err = d.mapTable(rec("wp_2_options"))
+	is.True(err != nil)
+}
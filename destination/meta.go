@@ -0,0 +1,331 @@
+package destination
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/conduitio/conduit-commons/opencdc"
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/surrealdb/surrealdb.go"
+)
+
+// Formats meta values can be decoded from.
+const (
+	metaDecodeJSON = "json"
+	metaDecodePHP  = "php"
+	metaDecodeAuto = "auto"
+)
+
+// MetaConfig folds a meta table, with one row per key and value like
+// wp_postmeta, into an object field of its parent records. Rows of the meta
+// table are not written to a table of their own.
+type MetaConfig struct {
+	// Parent is the table the meta rows belong to.
+	Parent string `yaml:"parent"`
+	// ParentField holds the id of the parent record, e.g. "post_id".
+	ParentField string `yaml:"parentField"`
+	// KeyField and ValueField hold the key and the value, "meta_key" and
+	// "meta_value" by default.
+	KeyField   string `yaml:"keyField"`
+	ValueField string `yaml:"valueField"`
+	// Field is the object field of the parent the values are written to,
+	// "meta" by default.
+	Field string `yaml:"field"`
+	// Decode decodes values stored as "json" or serialized "php", or tries
+	// both with "auto". Values that can't be decoded are written as they are.
+	Decode string `yaml:"decode"`
+}
+
+// validate checks the meta settings and fills in the defaults.
+func (mc *MetaConfig) validate() error {
+	if mc.Parent == "" || mc.ParentField == "" {
+		return fmt.Errorf("parent and parentField are required")
+	}
+	if mc.KeyField == "" {
+		mc.KeyField = "meta_key"
+	}
+	if mc.ValueField == "" {
+		mc.ValueField = "meta_value"
+	}
+	if mc.Field == "" {
+		mc.Field = "meta"
+	}
+	if !targetNamePattern.MatchString(mc.Field) {
+		return fmt.Errorf("invalid field %q", mc.Field)
+	}
+	switch mc.Decode {
+	case "", metaDecodeJSON, metaDecodePHP, metaDecodeAuto:
+		return nil
+	default:
+		return fmt.Errorf("unknown decode %q", mc.Decode)
+	}
+}
+
+// prepareMeta makes sure the payload of a meta row holds its parent, key and
+// decoded value. Deletes usually only carry the key of the row, so the parent
+// and key are taken from the payload before the change. The payload before an
+// update is decoded as well, to find rows that moved to another parent or key.
+func (d *Destination) prepareMeta(mc *MetaConfig, r *opencdc.Record) error {
+	afterMap, ok := r.Payload.After.(opencdc.StructuredData)
+	if !ok {
+		return fmt.Errorf("unexpected type for r.Payload.After: %T", r.Payload.After)
+	}
+	if r.Operation == opencdc.OperationDelete || r.Operation == opencdc.OperationUpdate {
+		if err := d.structuredDataFormatter(&r.Payload.Before); err != nil {
+			return fmt.Errorf("failed to get payload before the change: %w", err)
+		}
+	}
+	if beforeMap, ok := r.Payload.Before.(opencdc.StructuredData); ok && r.Operation == opencdc.OperationDelete {
+		for _, field := range []string{mc.ParentField, mc.KeyField} {
+			if _, ok := afterMap[field]; !ok {
+				afterMap[field] = beforeMap[field]
+			}
+		}
+	}
+	if afterMap[mc.ParentField] == nil || afterMap[mc.KeyField] == nil {
+		return fmt.Errorf("meta row is missing %s or %s", mc.ParentField, mc.KeyField)
+	}
+	if value, ok := afterMap[mc.ValueField].(string); ok && mc.Decode != "" {
+		afterMap[mc.ValueField] = d.decodeMetaValue(value, mc.Decode)
+	}
+	return nil
+}
+
+// metaEntry is the parent and key a meta row is written to.
+type metaEntry struct {
+	parent interface{}
+	key    string
+}
+
+// metaMovedFrom returns the parents and keys the meta rows of rs had before an
+// update moved them to another parent or key, with nil for the rows that
+// didn't move.
+func metaMovedFrom(mc *MetaConfig, operation opencdc.Operation, rs []*opencdc.Record) []*metaEntry {
+	previous := make([]*metaEntry, len(rs))
+	if operation != opencdc.OperationUpdate {
+		return previous
+	}
+	for i, r := range rs {
+		afterMap, _ := r.Payload.After.(opencdc.StructuredData)
+		beforeMap, _ := r.Payload.Before.(opencdc.StructuredData)
+		if beforeMap[mc.ParentField] == nil || beforeMap[mc.KeyField] == nil {
+			continue
+		}
+		old := &metaEntry{parent: beforeMap[mc.ParentField], key: fmt.Sprint(beforeMap[mc.KeyField])}
+		if !sameID(old.parent, afterMap[mc.ParentField]) || old.key != fmt.Sprint(afterMap[mc.KeyField]) {
+			previous[i] = old
+		}
+	}
+	return previous
+}
+
+// decodeMetaValue decodes a value stored as JSON or serialized PHP, and returns
+// it unchanged if it can't be decoded.
+func (d *Destination) decodeMetaValue(value, format string) interface{} {
+	trimmed := strings.TrimSpace(value)
+	if format == metaDecodePHP || format == metaDecodeAuto {
+		if decoded, rest, err := decodePHP(trimmed); err == nil && rest == "" {
+			return decoded
+		}
+	}
+	if format == metaDecodeJSON || (format == metaDecodeAuto && (strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "["))) {
+		var decoded interface{}
+		dec := json.NewDecoder(strings.NewReader(trimmed))
+		dec.UseNumber()
+		if err := dec.Decode(&decoded); err == nil && !dec.More() {
+			if normalized, err := d.normalizeNumber(decoded); err == nil {
+				return normalized
+			}
+		}
+	}
+	return value
+}
+
+// metaStatements appends the statements that write meta rows into the object
+// field of their parents to query, and adds the variables they use to vars.
+// Creates and updates set the key, creating parents that don't exist yet, and
+// deletes unset it. previous holds the parents and keys updated rows moved away
+// from, as returned by metaMovedFrom, which are unset first. It returns the
+// index of the payload of every statement.
+func metaStatements(query *strings.Builder, vars map[string]interface{}, mc *MetaConfig, operation opencdc.Operation, payloads []*opencdc.Data, previous []*metaEntry) ([]int, error) {
+	var owners []int
+	unset := func(parent, key string) {
+		fmt.Fprintf(query, "UPDATE type::thing($parent_tb, %[1]s) SET %[3]s = object::from_entries(object::entries(%[3]s ?? {})[WHERE $this[0] != %[2]s]);\n", parent, key, mc.Field)
+	}
+
+	vars["parent_tb"] = mc.Parent
+	for i, payload := range payloads {
+		payloadMap, ok := (*payload).(opencdc.StructuredData)
+		if !ok {
+			return nil, fmt.Errorf("unexpected type for payload: %T", *payload)
+		}
+		vars[fmt.Sprintf("parent%d", i)] = payloadMap[mc.ParentField]
+		vars[fmt.Sprintf("key%d", i)] = fmt.Sprint(payloadMap[mc.KeyField])
+
+		if i < len(previous) && previous[i] != nil {
+			vars[fmt.Sprintf("previous_parent%d", i)] = previous[i].parent
+			vars[fmt.Sprintf("previous_key%d", i)] = previous[i].key
+			unset(fmt.Sprintf("$previous_parent%d", i), fmt.Sprintf("$previous_key%d", i))
+			owners = append(owners, i)
+		}
+
+		// values left out by the null policy unset the key, like deletes
+		value, hasValue := payloadMap[mc.ValueField]
+		switch {
+		case operation == opencdc.OperationDelete || !hasValue:
+			unset(fmt.Sprintf("$parent%d", i), fmt.Sprintf("$key%d", i))
+		default:
+			vars[fmt.Sprintf("value%d", i)] = value
+			fmt.Fprintf(query, "UPSERT type::thing($parent_tb, $parent%[1]d) MERGE { %[2]s: object::from_entries([[$key%[1]d, $value%[1]d]]) };\n", i, mc.Field)
+		}
+		owners = append(owners, i)
+	}
+	return owners, nil
+}
+
+// metaWrite writes a run of meta rows into the object field of their parents.
+// rs are the records of the payloads.
+func (d *Destination) metaWrite(ctx context.Context, t *target, tableName string, operation opencdc.Operation, payloads []*opencdc.Data, rs []*opencdc.Record) ([]bool, error) {
+	landed := make([]bool, len(payloads))
+	mc := d.tableConfig(tableName).Meta
+
+	var query strings.Builder
+	vars := make(map[string]interface{})
+	owners, err := metaStatements(&query, vars, mc, operation, payloads, metaMovedFrom(mc, operation, rs))
+	if err != nil {
+		return landed, err
+	}
+
+	results, err := surrealdb.Query[interface{}](t.db, query.String(), vars)
+	if err != nil {
+		sdk.Logger(ctx).Error().Msg("Failed to write meta records: " + err.Error())
+		return landed, fmt.Errorf("failed to write meta records: %w", err)
+	}
+	// a row has landed once all of its statements have
+	for j, result := range *results {
+		i := owners[j]
+		if result.Status != "OK" {
+			return landed, fmt.Errorf("meta record %s: %w", recordName(tableName, payloads[i]), statementError{result.Result})
+		}
+		landed[i] = j+1 == len(owners) || owners[j+1] != i
+	}
+	return landed, nil
+}
+
+// decodePHP decodes the value at the start of a string serialized with PHP's
+// serialize, and returns the text after it. Arrays with the keys 0 to n-1
+// become lists, other arrays and objects become maps.
+func decodePHP(s string) (interface{}, string, error) {
+	if strings.HasPrefix(s, "N;") {
+		return nil, s[2:], nil
+	}
+	if len(s) < 2 || s[1] != ':' {
+		return nil, s, fmt.Errorf("invalid serialized value")
+	}
+	kind, body := s[0], s[2:]
+
+	switch kind {
+	case 'b', 'i', 'd':
+		end := strings.IndexByte(body, ';')
+		if end < 0 {
+			return nil, s, fmt.Errorf("unterminated value")
+		}
+		raw, rest := body[:end], body[end+1:]
+		switch kind {
+		case 'b':
+			return raw == "1", rest, nil
+		case 'i':
+			n, err := strconv.ParseInt(raw, 10, 64)
+			return n, rest, err
+		default:
+			f, err := strconv.ParseFloat(raw, 64)
+			return f, rest, err
+		}
+	case 's':
+		str, rest, err := decodePHPString(body)
+		if err != nil {
+			return nil, s, err
+		}
+		if !strings.HasPrefix(rest, ";") {
+			return nil, s, fmt.Errorf("unterminated string")
+		}
+		return str, rest[1:], nil
+	case 'a':
+		return decodePHPArray(body)
+	case 'O':
+		// objects are the class name followed by their properties as an array
+		_, rest, err := decodePHPString(body)
+		if err != nil || !strings.HasPrefix(rest, ":") {
+			return nil, s, fmt.Errorf("invalid object")
+		}
+		return decodePHPArray(rest[1:])
+	default:
+		return nil, s, fmt.Errorf("unsupported serialized type %q", kind)
+	}
+}
+
+// decodePHPString decodes a length prefixed, quoted string like 5:"hello".
+func decodePHPString(s string) (string, string, error) {
+	colon := strings.IndexByte(s, ':')
+	if colon < 0 {
+		return "", s, fmt.Errorf("invalid string")
+	}
+	n, err := strconv.Atoi(s[:colon])
+	if err != nil || n < 0 {
+		return "", s, fmt.Errorf("invalid string length")
+	}
+	start := colon + 2
+	end := start + n
+	if len(s) < end+1 || s[colon+1] != '"' || s[end] != '"' {
+		return "", s, fmt.Errorf("invalid string")
+	}
+	return s[start:end], s[end+1:], nil
+}
+
+// decodePHPArray decodes the element count and elements of an array, like
+// 2:{i:0;s:1:"a";i:1;s:1:"b";}.
+func decodePHPArray(s string) (interface{}, string, error) {
+	colon := strings.IndexByte(s, ':')
+	if colon < 0 {
+		return nil, s, fmt.Errorf("invalid array")
+	}
+	n, err := strconv.Atoi(s[:colon])
+	if err != nil || n < 0 || !strings.HasPrefix(s[colon+1:], "{") {
+		return nil, s, fmt.Errorf("invalid array")
+	}
+	rest := s[colon+2:]
+
+	keys := make([]string, n)
+	values := make([]interface{}, n)
+	sequential := true
+	for i := 0; i < n; i++ {
+		var key, value interface{}
+		if key, rest, err = decodePHP(rest); err != nil {
+			return nil, s, err
+		}
+		if value, rest, err = decodePHP(rest); err != nil {
+			return nil, s, err
+		}
+		if k, ok := key.(int64); !ok || k != int64(i) {
+			sequential = false
+		}
+		keys[i] = fmt.Sprint(key)
+		values[i] = value
+	}
+	if !strings.HasPrefix(rest, "}") {
+		return nil, s, fmt.Errorf("unterminated array")
+	}
+	rest = rest[1:]
+
+	if sequential {
+		return values, rest, nil
+	}
+	obj := make(map[string]interface{}, n)
+	for i, key := range keys {
+		obj[key] = values[i]
+	}
+	return obj, rest, nil
+}
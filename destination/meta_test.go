@@ -0,0 +1,73 @@
+package destination
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/conduitio/conduit-commons/opencdc"
+	"github.com/matryer/is"
+)
+
+func TestDecodeMetaValue(t *testing.T) {
+	is := is.New(t)
+	d := &Destination{}
+
+	is.Equal(d.decodeMetaValue(`a:2:{i:0;s:3:"red";i:1;s:4:"blue";}`, metaDecodePHP), []interface{}{"red", "blue"})
+	is.Equal(d.decodeMetaValue(`a:2:{s:5:"width";i:1024;s:4:"crop";b:1;}`, metaDecodeAuto), map[string]interface{}{"width": int64(1024), "crop": true})
+	is.Equal(d.decodeMetaValue(`{"a": [1, 2.5]}`, metaDecodeAuto), map[string]interface{}{"a": []interface{}{int64(1), 2.5}})
+	is.Equal(d.decodeMetaValue(`plain text`, metaDecodeAuto), "plain text")
+	is.Equal(d.decodeMetaValue(`s:3:"abc"`, metaDecodePHP), `s:3:"abc"`) // unterminated
+}
+
+func TestMetaStatements(t *testing.T) {
+	is := is.New(t)
+
+	mc := &MetaConfig{Parent: "wp_posts", ParentField: "post_id"}
+	is.NoErr(mc.validate())
+
+	r := &opencdc.Record{
+		Operation: opencdc.OperationDelete,
+		Payload: opencdc.Change{
+			Before: opencdc.StructuredData{"meta_id": 7, "post_id": 5, "meta_key": "color"},
+			After:  opencdc.StructuredData{"id": 7},
+		},
+	}
+	d := &Destination{}
+	is.NoErr(d.prepareMeta(mc, r))
+
+	var query strings.Builder
+	vars := make(map[string]interface{})
+	owners, err := metaStatements(&query, vars, mc, r.Operation, []*opencdc.Data{&r.Payload.After}, nil)
+	is.NoErr(err)
+	is.Equal(owners, []int{0})
+	is.Equal(query.String(), "UPDATE type::thing($parent_tb, $parent0) SET meta = object::from_entries(object::entries(meta ?? {})[WHERE $this[0] != $key0]);\n")
+	is.Equal(vars["parent0"], 5)
+	is.Equal(vars["key0"], "color")
+
+	// a row that moved to another key is unset at the old one, and parents
+	// that don't exist yet are created
+	r = &opencdc.Record{
+		Operation: opencdc.OperationUpdate,
+		Payload: opencdc.Change{
+			Before: opencdc.RawData(`{"meta_id": 7, "post_id": 5, "meta_key": "color"}`),
+			After:  opencdc.StructuredData{"id": 7, "post_id": 5, "meta_key": "colour", "meta_value": "red"},
+		},
+	}
+	is.NoErr(d.prepareMeta(mc, r))
+	previous := metaMovedFrom(mc, r.Operation, []*opencdc.Record{r})
+	is.Equal(previous[0].key, "color")
+
+	query.Reset()
+	vars = make(map[string]interface{})
+	owners, err = metaStatements(&query, vars, mc, r.Operation, []*opencdc.Data{&r.Payload.After}, previous)
+	is.NoErr(err)
+	is.Equal(owners, []int{0, 0})
+	is.Equal(query.String(), "UPDATE type::thing($parent_tb, $previous_parent0) SET meta = object::from_entries(object::entries(meta ?? {})[WHERE $this[0] != $previous_key0]);\n"+
+		"UPSERT type::thing($parent_tb, $parent0) MERGE { meta: object::from_entries([[$key0, $value0]]) };\n")
+	is.Equal(vars["previous_key0"], "color")
+	is.Equal(vars["value0"], "red")
+
+	// rows that keep their parent and key don't unset anything
+	r.Payload.Before = opencdc.StructuredData{"meta_id": 7, "post_id": 5, "meta_key": "colour"}
+	is.Equal(metaMovedFrom(mc, r.Operation, []*opencdc.Record{r}), []*metaEntry{nil})
+}
@@ -0,0 +1,50 @@
+package destination
+
+import (
+	"unicode/utf8"
+
+	"github.com/conduitio/conduit-commons/opencdc"
+)
+
+// setRecordMetadata writes where a record came from into MetadataField of its
+// payload: the operation, the position, the time it was read and the metadata
+// entries in MetadataKeys, or all of them if none are configured. Deletes are
+// left alone, as the record is gone afterwards.
+func (d *Destination) setRecordMetadata(r *opencdc.Record, payloadMap opencdc.StructuredData) {
+	if r.Operation == opencdc.OperationDelete {
+		return
+	}
+
+	info := map[string]interface{}{
+		"operation": r.Operation.String(),
+	}
+	info["position"] = positionValue(r.Position)
+	if readAt, err := r.Metadata.GetReadAt(); err == nil {
+		info["read_at"] = datetime(readAt.UTC())
+	}
+
+	metadata := make(map[string]interface{})
+	if len(d.config.MetadataKeys) == 0 {
+		for key, value := range r.Metadata {
+			metadata[key] = value
+		}
+	} else {
+		for _, key := range d.config.MetadataKeys {
+			if value, ok := r.Metadata[key]; ok {
+				metadata[key] = value
+			}
+		}
+	}
+	info["metadata"] = metadata
+
+	payloadMap[d.config.MetadataField] = info
+}
+
+// positionValue returns a position as a string, as positions are usually JSON,
+// or as bytes if it is binary.
+func positionValue(p opencdc.Position) interface{} {
+	if utf8.Valid(p) {
+		return string(p)
+	}
+	return []byte(p)
+}
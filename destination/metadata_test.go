@@ -0,0 +1,37 @@
+package destination
+
+import (
+	"testing"
+	"time"
+
+	"github.com/conduitio/conduit-commons/opencdc"
+	"github.com/matryer/is"
+)
+
+func TestSetRecordMetadata(t *testing.T) {
+	is := is.New(t)
+	d := &Destination{config: Config{MetadataField: "_meta", MetadataKeys: []string{"opencdc.collection"}}}
+
+	r := opencdc.Record{
+		Operation: opencdc.OperationUpdate,
+		Position:  opencdc.Position(`{"lsn":5}`),
+		Metadata: opencdc.Metadata{
+			"opencdc.collection": "wp_posts",
+			"opencdc.readAt":     "1700000000000000000",
+			"secret":             "x",
+		},
+	}
+	payload := opencdc.StructuredData{"id": 1}
+	d.setRecordMetadata(&r, payload)
+	is.Equal(payload["_meta"], map[string]interface{}{
+		"operation": "update",
+		"position":  `{"lsn":5}`,
+		"read_at":   datetime(time.Unix(0, 1700000000000000000).UTC()),
+		"metadata":  map[string]interface{}{"opencdc.collection": "wp_posts"},
+	})
+
+	r.Operation = opencdc.OperationDelete
+	payload = opencdc.StructuredData{"id": 1}
+	d.setRecordMetadata(&r, payload)
+	is.Equal(payload, opencdc.StructuredData{"id": 1})
+}
@@ -0,0 +1,223 @@
+package destination
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/conduitio/conduit-commons/opencdc"
+)
+
+// Ways nested field names are written.
+const (
+	fieldNamesKeep    = "keep"
+	fieldNamesExpand  = "expand"
+	fieldNamesFlatten = "flatten"
+)
+
+// invalidFieldChars matches the characters that are replaced when field names
+// are sanitized.
+var invalidFieldChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// reservedFieldNames are SurrealQL keywords that get an underscore appended
+// when field names are sanitized, as they can't be used unescaped in queries.
+var reservedFieldNames = map[string]bool{
+	"AND": true, "AS": true, "CONTAINS": true, "CONTAINSALL": true, "CONTAINSANY": true,
+	"CONTAINSNONE": true, "CONTAINSNOT": true, "CONTENT": true, "CREATE": true, "DEFINE": true,
+	"DELETE": true, "ELSE": true, "END": true, "FALSE": true, "FETCH": true, "FROM": true,
+	"GROUP": true, "IF": true, "IN": true, "INSERT": true, "INSIDE": true, "IS": true,
+	"LET": true, "LIMIT": true, "MERGE": true, "NONE": true, "NOT": true, "NULL": true,
+	"ONLY": true, "OR": true, "ORDER": true, "OUTSIDE": true, "RELATE": true, "REMOVE": true,
+	"RETURN": true, "SELECT": true, "SET": true, "SPLIT": true, "START": true, "THEN": true,
+	"TRUE": true, "UNSET": true, "UPDATE": true, "UPSERT": true, "VALUE": true, "WHERE": true,
+	"WITH": true,
+}
+
+// sanitizeFieldName returns name with every character other than letters,
+// digits and underscores replaced by an underscore. Names starting with a digit
+// get an underscore put in front, and SurrealQL keywords one appended.
+func sanitizeFieldName(name string) string {
+	s := invalidFieldChars.ReplaceAllString(name, "_")
+	if s == "" {
+		return "_"
+	}
+	if s[0] >= '0' && s[0] <= '9' {
+		s = "_" + s
+	}
+	if reservedFieldNames[strings.ToUpper(s)] {
+		s += "_"
+	}
+	return s
+}
+
+// normalizeFieldNames expands or flattens the fields of a payload and
+// sanitizes their names, as configured. The id is left alone. Fields whose
+// names end up the same are rejected.
+func (d *Destination) normalizeFieldNames(payloadMap opencdc.StructuredData) (opencdc.StructuredData, error) {
+	var out map[string]interface{}
+	var err error
+	switch d.config.FieldNames {
+	case fieldNamesExpand:
+		out, err = expandFields(payloadMap)
+	case fieldNamesFlatten:
+		out = make(map[string]interface{}, len(payloadMap))
+		err = flattenFields(out, "", payloadMap)
+	default:
+		out = payloadMap
+	}
+	if err != nil {
+		return nil, err
+	}
+	if d.config.SanitizeFieldNames {
+		sanitized, err := sanitizeFields(out)
+		if err != nil {
+			return nil, err
+		}
+		out = sanitized
+	}
+	return out, nil
+}
+
+// expandFields turns dotted keys like "address.city" into nested objects.
+func expandFields(m map[string]interface{}) (map[string]interface{}, error) {
+	out := make(map[string]interface{}, len(m))
+	for key, value := range m {
+		if key == "id" || !strings.Contains(key, ".") {
+			if existing, ok := out[key].(map[string]interface{}); ok {
+				return nil, fmt.Errorf("field %s conflicts with nested fields %v", key, existing)
+			}
+			out[key] = value
+			continue
+		}
+
+		parts := strings.Split(key, ".")
+		obj := out
+		for _, part := range parts[:len(parts)-1] {
+			switch next := obj[part].(type) {
+			case map[string]interface{}:
+				obj = next
+			case nil:
+				nested := make(map[string]interface{})
+				obj[part] = nested
+				obj = nested
+			default:
+				return nil, fmt.Errorf("field %s conflicts with field %s", key, part)
+			}
+		}
+		last := parts[len(parts)-1]
+		if _, ok := obj[last]; ok {
+			return nil, fmt.Errorf("field %s is set more than once", key)
+		}
+		obj[last] = value
+	}
+	return out, nil
+}
+
+// flattenFields writes the fields of m into out, with nested objects turned
+// into underscored keys like "address_city".
+func flattenFields(out map[string]interface{}, prefix string, m map[string]interface{}) error {
+	for key, value := range m {
+		name := prefix + key
+		if nested, ok := asObject(value); ok && key != "id" {
+			if err := flattenFields(out, name+"_", nested); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, ok := out[name]; ok {
+			return fmt.Errorf("field %s is set more than once", name)
+		}
+		out[name] = value
+	}
+	return nil
+}
+
+// sanitizeFields sanitizes the keys of m and of the objects nested in it.
+func sanitizeFields(m map[string]interface{}) (map[string]interface{}, error) {
+	out := make(map[string]interface{}, len(m))
+	origins := make(map[string]string, len(m))
+	for key, value := range m {
+		name := key
+		if key != "id" {
+			name = sanitizeFieldName(key)
+		}
+		if origin, ok := origins[name]; ok {
+			return nil, fmt.Errorf("fields %s and %s are both written as %s", origin, key, name)
+		}
+		origins[name] = key
+
+		sanitized, err := sanitizeValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", key, err)
+		}
+		out[name] = sanitized
+	}
+	return out, nil
+}
+
+func sanitizeValue(value interface{}) (interface{}, error) {
+	if nested, ok := asObject(value); ok {
+		return sanitizeFields(nested)
+	}
+	if items, ok := value.([]interface{}); ok {
+		out := make([]interface{}, len(items))
+		for i, item := range items {
+			sanitized, err := sanitizeValue(item)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = sanitized
+		}
+		return out, nil
+	}
+	return value, nil
+}
+
+// asObject returns value as a map if it is a nested object.
+func asObject(value interface{}) (map[string]interface{}, bool) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return v, true
+	case opencdc.StructuredData:
+		return v, true
+	default:
+		return nil, false
+	}
+}
+
+// normalizeSchemaFieldNames applies the same changes to the fields of a payload
+// schema that normalizeFieldNames applies to payloads.
+func (d *Destination) normalizeSchemaFieldNames(fields []schemaField) []schemaField {
+	if d.config.FieldNames == fieldNamesFlatten {
+		fields = flattenSchemaFields("", false, fields)
+	}
+	if d.config.SanitizeFieldNames {
+		out := make([]schemaField, len(fields))
+		for i, field := range fields {
+			field.Name = sanitizeFieldName(field.Name)
+			out[i] = field
+		}
+		fields = out
+	}
+	return fields
+}
+
+// flattenSchemaFields replaces record fields with their nested fields, named
+// the way flattenFields names them. Nested fields of optional records become
+// optional themselves.
+func flattenSchemaFields(prefix string, optional bool, fields []schemaField) []schemaField {
+	var out []schemaField
+	for _, field := range fields {
+		fieldOptional, _ := splitType(field.Type)
+		if len(field.Nested) > 0 {
+			out = append(out, flattenSchemaFields(prefix+field.Name+"_", optional || fieldOptional, field.Nested)...)
+			continue
+		}
+		field.Name = prefix + field.Name
+		if optional && !fieldOptional {
+			field.Type = optionalType(field.Type)
+		}
+		out = append(out, field)
+	}
+	return out
+}
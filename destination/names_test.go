@@ -0,0 +1,54 @@
+package destination
+
+import (
+	"testing"
+
+	"github.com/conduitio/conduit-commons/opencdc"
+	"github.com/matryer/is"
+)
+
+func TestNormalizeFieldNames(t *testing.T) {
+	is := is.New(t)
+
+	d := &Destination{config: Config{FieldNames: fieldNamesExpand, SanitizeFieldNames: true}}
+	got, err := d.normalizeFieldNames(opencdc.StructuredData{
+		"id": 1, "address.city": "Berlin", "address.zip-code": "10115", "value": 3, "2fa": true,
+	})
+	is.NoErr(err)
+	is.Equal(got, opencdc.StructuredData{
+		"id":      1,
+		"address": map[string]interface{}{"city": "Berlin", "zip_code": "10115"},
+		"value_":  3,
+		"_2fa":    true,
+	})
+
+	_, err = d.normalizeFieldNames(opencdc.StructuredData{"address": "x", "address.city": "Berlin"})
+	is.True(err != nil)
+
+	d.config.FieldNames = fieldNamesFlatten
+	got, err = d.normalizeFieldNames(opencdc.StructuredData{
+		"id": 1, "address": map[string]interface{}{"city": "Berlin", "geo": map[string]interface{}{"lat": 52.5}},
+	})
+	is.NoErr(err)
+	is.Equal(got, opencdc.StructuredData{"id": 1, "address_city": "Berlin", "address_geo_lat": 52.5})
+
+	_, err = d.normalizeFieldNames(opencdc.StructuredData{"a-b": 1, "a_b": 2})
+	is.True(err != nil)
+}
+
+func TestFlattenSchemaFields(t *testing.T) {
+	is := is.New(t)
+
+	got := flattenSchemaFields("", false, []schemaField{
+		{Name: "name", Type: "string"},
+		{Name: "address", Type: "option<object | null>", Nested: []schemaField{
+			{Name: "city", Type: "string"},
+			{Name: "zip", Type: "option<string | null>"},
+		}},
+	})
+	is.Equal(got, []schemaField{
+		{Name: "name", Type: "string"},
+		{Name: "address_city", Type: "option<string | null>"},
+		{Name: "address_zip", Type: "option<string | null>"},
+	})
+}
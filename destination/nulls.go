@@ -0,0 +1,63 @@
+package destination
+
+import (
+	"fmt"
+
+	"github.com/conduitio/conduit-commons/opencdc"
+)
+
+// Policies for null values.
+const (
+	nullsKeep    = "keep"
+	nullsNone    = "none"
+	nullsDefault = "default"
+)
+
+// NullsConfig decides how the null values of a table are written.
+type NullsConfig struct {
+	// Policy is "keep", "none" or "default", and overrides NullValues.
+	Policy string `yaml:"policy"`
+	// Defaults are the values null fields are replaced with under the
+	// "default" policy. Null fields without a default are left out.
+	Defaults map[string]interface{} `yaml:"defaults"`
+}
+
+// validate checks the null policy of a table.
+func (nc NullsConfig) validate() error {
+	switch nc.Policy {
+	case "", nullsKeep, nullsNone, nullsDefault:
+		return nil
+	default:
+		return fmt.Errorf("unknown policy %q", nc.Policy)
+	}
+}
+
+// nullPolicy returns the null policy of a table.
+func (d *Destination) nullPolicy(tableName string) string {
+	if policy := d.tableConfig(tableName).Nulls.Policy; policy != "" {
+		return policy
+	}
+	return d.config.NullValues
+}
+
+// applyNulls writes the null fields of a table in payloadMap the way its null
+// policy says: as NULL, left out so that they are NONE, or as their default.
+// Leaving a field out removes it from records that are updated, as updates
+// replace the whole record, and unsets it in fields that are merged into.
+func (d *Destination) applyNulls(tableName string, payloadMap opencdc.StructuredData) {
+	policy := d.nullPolicy(tableName)
+	if policy == nullsKeep {
+		return
+	}
+	defaults := d.tableConfig(tableName).Nulls.Defaults
+	for field, value := range payloadMap {
+		if value != nil || field == "id" {
+			continue
+		}
+		if def, ok := defaults[field]; ok && policy == nullsDefault {
+			payloadMap[field] = def
+			continue
+		}
+		delete(payloadMap, field)
+	}
+}
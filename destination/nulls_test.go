@@ -0,0 +1,32 @@
+package destination
+
+import (
+	"testing"
+
+	"github.com/conduitio/conduit-commons/opencdc"
+	"github.com/matryer/is"
+)
+
+func TestApplyNulls(t *testing.T) {
+	is := is.New(t)
+
+	d := &Destination{
+		config: Config{NullValues: nullsNone},
+		tables: map[string]TableConfig{
+			"wp_posts": {Nulls: NullsConfig{Policy: nullsDefault, Defaults: map[string]interface{}{"post_parent": 0}}},
+		},
+	}
+
+	payload := opencdc.StructuredData{"id": 1, "post_author": nil, "title": "hi"}
+	d.applyNulls("wp_users", payload)
+	is.Equal(payload, opencdc.StructuredData{"id": 1, "title": "hi"})
+
+	payload = opencdc.StructuredData{"id": 1, "post_parent": nil, "post_author": nil}
+	d.applyNulls("wp_posts", payload)
+	is.Equal(payload, opencdc.StructuredData{"id": 1, "post_parent": 0})
+
+	d.config.NullValues = nullsKeep
+	payload = opencdc.StructuredData{"id": 1, "post_author": nil}
+	d.applyNulls("wp_users", payload)
+	is.Equal(payload, opencdc.StructuredData{"id": 1, "post_author": nil})
+}
@@ -0,0 +1,90 @@
+package destination
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/surrealdb/surrealdb.go/pkg/models"
+)
+
+// normalizeNumbers replaces the json.Number values in m, including nested ones,
+// with values that represent them exactly: integers become int64 and other
+// numbers either decimals or float64, depending on DecimalNumbers. Numbers that
+// can't be represented exactly are an error rather than being rounded. With
+// DecimalNumbers, floats become decimals as well, so that a field gets the same
+// type whether the payload was raw JSON or structured data.
+func (d *Destination) normalizeNumbers(m map[string]interface{}) error {
+	for k, v := range m {
+		n, err := d.normalizeNumber(v)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", k, err)
+		}
+		m[k] = n
+	}
+	return nil
+}
+
+func (d *Destination) normalizeNumber(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case json.Number:
+		return d.convertNumber(v)
+	case float64:
+		return d.convertFloat(v), nil
+	case float32:
+		return d.convertFloat(float64(v)), nil
+	case map[string]interface{}:
+		return v, d.normalizeNumbers(v)
+	case []interface{}:
+		for i, item := range v {
+			n, err := d.normalizeNumber(item)
+			if err != nil {
+				return nil, fmt.Errorf("item %d: %w", i, err)
+			}
+			v[i] = n
+		}
+		return v, nil
+	default:
+		return value, nil
+	}
+}
+
+// convertFloat converts a float into a decimal holding its shortest exact
+// representation if DecimalNumbers is enabled.
+func (d *Destination) convertFloat(f float64) interface{} {
+	if !d.config.DecimalNumbers || math.IsNaN(f) || math.IsInf(f, 0) {
+		return f
+	}
+	return models.DecimalString(strconv.FormatFloat(f, 'f', -1, 64))
+}
+
+// convertNumber converts a JSON number into an int64, a decimal or a float64.
+func (d *Destination) convertNumber(n json.Number) (interface{}, error) {
+	s := n.String()
+	if !strings.ContainsAny(s, ".eE") {
+		i, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("integer %s can't be represented as int64", s)
+		}
+		return i, nil
+	}
+
+	if d.config.DecimalNumbers {
+		return models.DecimalString(s), nil
+	}
+
+	// only accept floats that convert back to the same number
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, fmt.Errorf("number %s can't be represented as float: %w", s, err)
+	}
+	exact, _ := new(big.Rat).SetString(s)
+	roundTrip, _ := new(big.Rat).SetString(strconv.FormatFloat(f, 'g', -1, 64))
+	if exact == nil || roundTrip == nil || exact.Cmp(roundTrip) != 0 {
+		return nil, fmt.Errorf("number %s can't be represented exactly as float, enable %q to store it as decimal", s, ConfigDecimalNumbers)
+	}
+	return f, nil
+}
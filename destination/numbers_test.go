@@ -0,0 +1,40 @@
+package destination
+
+import (
+	"testing"
+
+	"github.com/conduitio/conduit-commons/opencdc"
+	"github.com/matryer/is"
+	"github.com/surrealdb/surrealdb.go/pkg/models"
+)
+
+func TestStructuredDataFormatter_Numbers(t *testing.T) {
+	is := is.New(t)
+	d := &Destination{}
+
+	var data opencdc.Data = opencdc.RawData(`{"ID": 9007199254740993, "ratio": 0.25, "tags": [1, 2.5]}`)
+	is.NoErr(d.structuredDataFormatter(&data))
+	is.Equal(data, opencdc.StructuredData{
+		"ID":    int64(9007199254740993),
+		"ratio": 0.25,
+		"tags":  []interface{}{int64(1), 2.5},
+	})
+
+	data = opencdc.RawData(`{"price": 12345678901234567.89}`)
+	is.True(d.structuredDataFormatter(&data) != nil)
+
+	d.config.DecimalNumbers = true
+	is.NoErr(d.structuredDataFormatter(&data))
+	is.Equal(data, opencdc.StructuredData{"price": models.DecimalString("12345678901234567.89")})
+
+	// structured payloads get the same types as raw ones
+	data = opencdc.RawData(`{"ratio": 1.5, "count": 2}`)
+	is.NoErr(d.structuredDataFormatter(&data))
+	is.Equal(data, opencdc.StructuredData{"ratio": models.DecimalString("1.5"), "count": int64(2)})
+	data = opencdc.StructuredData{"ratio": 1.5, "count": int64(2)}
+	is.NoErr(d.structuredDataFormatter(&data))
+	is.Equal(data, opencdc.StructuredData{"ratio": models.DecimalString("1.5"), "count": int64(2)})
+
+	data = opencdc.RawData(`{"ID": 18446744073709551616}`)
+	is.True(d.structuredDataFormatter(&data) != nil)
+}
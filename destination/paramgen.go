@@ -8,17 +8,78 @@ import (
 )
 
 const (
-	ConfigDatabase     = "database"
-	ConfigDeleteOldKey = "delete_old_key"
-	ConfigNamespace    = "namespace"
-	ConfigPassword     = "password"
-	ConfigScope        = "scope"
-	ConfigUrl          = "url"
-	ConfigUsername     = "username"
+	ConfigChangeLog          = "change_log"
+	ConfigCheckpointTable    = "checkpoint_table"
+	ConfigCheckpoints        = "checkpoints"
+	ConfigCoalesce           = "coalesce"
+	ConfigCoerceTypes        = "coerce_types"
+	ConfigCreateTargets      = "create_targets"
+	ConfigDatabase           = "database"
+	ConfigDeadLetterTable    = "dead_letter_table"
+	ConfigDeadLetters        = "dead_letters"
+	ConfigDecimalNumbers     = "decimal_numbers"
+	ConfigDefineSchema       = "define_schema"
+	ConfigDeleteOldKey       = "delete_old_key"
+	ConfigFieldNames         = "field_names"
+	ConfigMaxConcurrency     = "max_concurrency"
+	ConfigMetadataField      = "metadata_field"
+	ConfigMetadataKeys       = "metadata_keys"
+	ConfigNamespace          = "namespace"
+	ConfigNullValues         = "null_values"
+	ConfigPassword           = "password"
+	ConfigRawPayloadField    = "raw_payload_field"
+	ConfigRawPayloads        = "raw_payloads"
+	ConfigRouteDatabase      = "route_database"
+	ConfigRouteNamespace     = "route_namespace"
+	ConfigSanitizeFieldNames = "sanitize_field_names"
+	ConfigSchemaEvolution    = "schema_evolution"
+	ConfigScope              = "scope"
+	ConfigUrl                = "url"
+	ConfigUsername           = "username"
+	ConfigVersionField       = "version_field"
+	ConfigVersionMetadata    = "version_metadata"
 )
 
 func (Config) Parameters() map[string]config.Parameter {
 	return map[string]config.Parameter{
+		ConfigChangeLog: {
+			Default:     "off",
+			Description: "ChangeLog appends every change as a new record to a \"<table>_changes\" table, holding the record, the operation, the payloads before and after the change, the position and the metadata: \"off\" logs nothing, \"append\" logs changes alongside writing them to the table, \"only\" logs them instead. Can't be used with Coalesce, which drops changes.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{
+				config.ValidationInclusion{List: []string{"off", "append", "only"}},
+			},
+		},
+		ConfigCheckpointTable: {
+			Default:     "_conduit_checkpoint",
+			Description: "CheckpointTable is the table the checkpoints are stored in, one record per source connector and table.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{},
+		},
+		ConfigCheckpoints: {
+			Default:     "false",
+			Description: "Checkpoints stores the position of the last record of each source written to each table in CheckpointTable, in the same transaction as the data. Records at or before a stored position are skipped when they are replayed after a crash.",
+			Type:        config.ParameterTypeBool,
+			Validations: []config.Validation{},
+		},
+		ConfigCoalesce: {
+			Default:     "false",
+			Description: "Coalesce reduces multiple changes to the same record within a batch to their net effect before writing them, e.g. several updates become the last one and a create followed by a delete is dropped.",
+			Type:        config.ParameterTypeBool,
+			Validations: []config.Validation{},
+		},
+		ConfigCoerceTypes: {
+			Default:     "false",
+			Description: "CoerceTypes converts payload values into native SurrealDB datetimes, decimals, durations, uuids and bytes according to the payload schema. Types configured per table in the relations schema are always applied.",
+			Type:        config.ParameterTypeBool,
+			Validations: []config.Validation{},
+		},
+		ConfigCreateTargets: {
+			Default:     "false",
+			Description: "CreateTargets defines the namespaces and databases records are written to if they don't exist yet.",
+			Type:        config.ParameterTypeBool,
+			Validations: []config.Validation{},
+		},
 		ConfigDatabase: {
 			Default:     "",
 			Description: "Database is the database name for the SurrealDB server.",
@@ -27,12 +88,64 @@ func (Config) Parameters() map[string]config.Parameter {
 				config.ValidationRequired{},
 			},
 		},
+		ConfigDeadLetterTable: {
+			Default:     "_conduit_dlq",
+			Description: "DeadLetterTable is the table records that failed are put into.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{},
+		},
+		ConfigDeadLetters: {
+			Default:     "false",
+			Description: "DeadLetters puts records that SurrealDB rejects, or that can't be processed, into DeadLetterTable together with the error, instead of stopping the pipeline. Fields the table excludes are left out of the stored record. Failures to reach SurrealDB, and transaction conflicts that persist after being retried, still stop it.",
+			Type:        config.ParameterTypeBool,
+			Validations: []config.Validation{},
+		},
+		ConfigDecimalNumbers: {
+			Default:     "false",
+			Description: "DecimalNumbers stores JSON numbers with a fraction or exponent as SurrealDB decimals, so that e.g. money columns keep their exact value. Floats in structured payloads are stored as decimals as well, so a field gets the same type however the source encodes it. If it's disabled, they are stored as floats and JSON numbers that can't be stored exactly as float are rejected. Disabled by default, as enabling it changes the type of every fractional number already stored as float. Integers are always stored as int.",
+			Type:        config.ParameterTypeBool,
+			Validations: []config.Validation{},
+		},
+		ConfigDefineSchema: {
+			Default:     "false",
+			Description: "DefineSchema defines a table as SCHEMAFULL, with typed fields taken from the payload schema in the schema registry, the first time a record of the table is written.",
+			Type:        config.ParameterTypeBool,
+			Validations: []config.Validation{},
+		},
 		ConfigDeleteOldKey: {
 			Default:     "false",
 			Description: "We will always set an \"id\" field. If the incoming primary key is not \"id\", then \"id\" will get its value. DeleteOldKey is a flag to delete the old key and value from payload. Set to false if you want to keep the old key and value in the payload.",
 			Type:        config.ParameterTypeBool,
 			Validations: []config.Validation{},
 		},
+		ConfigFieldNames: {
+			Default:     "keep",
+			Description: "FieldNames decides how nested fields are written: \"keep\" writes them as they are, \"expand\" turns dotted keys like \"address.city\" into nested objects, \"flatten\" turns nested objects into underscored keys like \"address_city\".",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{
+				config.ValidationInclusion{List: []string{"keep", "expand", "flatten"}},
+			},
+		},
+		ConfigMaxConcurrency: {
+			Default:     "1",
+			Description: "MaxConcurrency is the number of tables that are written to SurrealDB in parallel. Records of a single table are always written in the order they arrived.",
+			Type:        config.ParameterTypeInt,
+			Validations: []config.Validation{
+				config.ValidationGreaterThan{V: 0},
+			},
+		},
+		ConfigMetadataField: {
+			Default:     "",
+			Description: "MetadataField is the field the operation, position, read time and metadata of a record are written to, e.g. \"_meta\". Relation edges get a copy of the field of the record that created them. Nothing is written if empty.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{},
+		},
+		ConfigMetadataKeys: {
+			Default:     "",
+			Description: "MetadataKeys are the metadata entries written to MetadataField. All entries are written if empty.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{},
+		},
 		ConfigNamespace: {
 			Default:     "",
 			Description: "Namespace is the namespace for the SurrealDB server.",
@@ -41,6 +154,14 @@ func (Config) Parameters() map[string]config.Parameter {
 				config.ValidationRequired{},
 			},
 		},
+		ConfigNullValues: {
+			Default:     "keep",
+			Description: "NullValues decides how null values are written: \"keep\" writes NULL, \"none\" leaves the field out so it is NONE, \"default\" writes the default configured for the field in the relations schema, or leaves it out if there is none. Relation events only skip fields that are NONE. Can be overridden per table.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{
+				config.ValidationInclusion{List: []string{"keep", "none", "default"}},
+			},
+		},
 		ConfigPassword: {
 			Default:     "",
 			Description: "Password is the password for the SurrealDB server.",
@@ -49,6 +170,46 @@ func (Config) Parameters() map[string]config.Parameter {
 				config.ValidationRequired{},
 			},
 		},
+		ConfigRawPayloadField: {
+			Default:     "data",
+			Description: "RawPayloadField is the field raw payloads are stored in.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{},
+		},
+		ConfigRawPayloads: {
+			Default:     "fail",
+			Description: "RawPayloads decides what happens to raw payloads that aren't a JSON object, like JSON arrays, CSV lines or binary blobs: \"fail\" rejects them, \"bytes\" and \"string\" store them in RawPayloadField as bytes or as a string. The id of the record is taken from its key.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{
+				config.ValidationInclusion{List: []string{"fail", "bytes", "string"}},
+			},
+		},
+		ConfigRouteDatabase: {
+			Default:     "",
+			Description: "RouteDatabase is a Go template of the database a record is written to, executed like RouteNamespace, e.g. `wp_{{.Payload.site}}`. Defaults to the configured database.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{},
+		},
+		ConfigRouteNamespace: {
+			Default:     "",
+			Description: "RouteNamespace is a Go template of the namespace a record is written to, executed with .Metadata, .Payload and .Table, e.g. `{{index .Metadata \"tenant\"}}`. Defaults to the configured namespace.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{},
+		},
+		ConfigSanitizeFieldNames: {
+			Default:     "false",
+			Description: "SanitizeFieldNames replaces characters other than letters, digits and underscores in field names with underscores, puts an underscore in front of names starting with a digit and appends one to SurrealQL keywords like \"value\" or \"select\".",
+			Type:        config.ParameterTypeBool,
+			Validations: []config.Validation{},
+		},
+		ConfigSchemaEvolution: {
+			Default:     "log",
+			Description: "SchemaEvolution decides what happens to breaking changes when the payload schema of a table changes: \"apply\" redefines the field with the new type, \"log\" keeps the old definition and logs a warning, \"fail\" stops the pipeline. New fields and widened types are always applied.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{
+				config.ValidationInclusion{List: []string{"apply", "log", "fail"}},
+			},
+		},
 		ConfigScope: {
 			Default:     "",
 			Description: "Scope is the scope for the SurrealDB server.",
@@ -73,5 +234,17 @@ func (Config) Parameters() map[string]config.Parameter {
 				config.ValidationRequired{},
 			},
 		},
+		ConfigVersionField: {
+			Default:     "",
+			Description: "VersionField is the name of the field holding the version or timestamp of a record. If set, creates, updates and deletes are only applied when the incoming version is newer than the one stored in SurrealDB, so replayed or out-of-order changes can't overwrite newer data.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{},
+		},
+		ConfigVersionMetadata: {
+			Default:     "",
+			Description: "VersionMetadata is the metadata key to take the version from, e.g. \"opencdc.readAt\". The value is stored in VersionField. If empty, the version is read from the payload.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{},
+		},
 	}
 }
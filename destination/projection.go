@@ -0,0 +1,98 @@
+package destination
+
+import (
+	"slices"
+
+	"github.com/conduitio/conduit-commons/opencdc"
+)
+
+// FieldsConfig selects and renames the fields of a table. It is applied to the
+// payload as it arrives, right after the key is mapped to "id", so all other
+// table settings refer to the renamed fields.
+type FieldsConfig struct {
+	// Include lists the fields that are kept, by their renamed names. All
+	// others are removed, except for the id. If empty, all fields are kept.
+	Include []string `yaml:"include"`
+	// Exclude lists fields that are removed, by their original names. They are
+	// removed from the payload before the change as well, so sensitive columns
+	// never reach SurrealDB.
+	Exclude []string `yaml:"exclude"`
+	// Rename maps field names to the names they are written with.
+	Rename map[string]string `yaml:"rename"`
+	// Constants are fields written with the same value for every record.
+	Constants map[string]interface{} `yaml:"constants"`
+}
+
+// projectPayload applies the field settings of the table of r to its payload.
+func (d *Destination) projectPayload(tableName string, r *opencdc.Record) {
+	fc := d.tableConfig(tableName).Fields
+	afterMap, _ := r.Payload.After.(opencdc.StructuredData)
+	beforeMap, _ := r.Payload.Before.(opencdc.StructuredData)
+	for _, field := range fc.Exclude {
+		delete(afterMap, field)
+		delete(beforeMap, field)
+	}
+	if afterMap == nil {
+		return
+	}
+
+	for from, to := range fc.Rename {
+		if value, ok := afterMap[from]; ok {
+			delete(afterMap, from)
+			afterMap[to] = value
+		}
+	}
+	if len(fc.Include) > 0 {
+		for field := range afterMap {
+			if field != "id" && !slices.Contains(fc.Include, field) {
+				delete(afterMap, field)
+			}
+		}
+	}
+	for field, value := range fc.Constants {
+		afterMap[field] = value
+	}
+}
+
+// projectFields applies the field settings of a table to the fields of its
+// payload schema.
+func (d *Destination) projectFields(tableName string, fields []schemaField) []schemaField {
+	fc := d.tableConfig(tableName).Fields
+	out := make([]schemaField, 0, len(fields)+len(fc.Constants))
+	for _, field := range fields {
+		if slices.Contains(fc.Exclude, field.Name) {
+			continue
+		}
+		if renamed, ok := fc.Rename[field.Name]; ok {
+			field.Name = renamed
+		}
+		if len(fc.Include) > 0 && field.Name != "id" && !slices.Contains(fc.Include, field.Name) {
+			continue
+		}
+		out = append(out, field)
+	}
+	for field := range fc.Constants {
+		if !hasField(out, field) {
+			out = append(out, schemaField{Name: field, Type: "any"})
+		}
+	}
+	return out
+}
+
+// renameTypes returns types with the field names renamed the way the field
+// settings of a table rename them.
+func (d *Destination) renameTypes(tableName string, types map[string]schemaField) map[string]schemaField {
+	rename := d.tableConfig(tableName).Fields.Rename
+	if len(rename) == 0 {
+		return types
+	}
+	out := make(map[string]schemaField, len(types))
+	for field, typ := range types {
+		if renamed, ok := rename[field]; ok {
+			field = renamed
+			typ.Name = renamed
+		}
+		out[field] = typ
+	}
+	return out
+}
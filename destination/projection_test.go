@@ -0,0 +1,45 @@
+package destination
+
+import (
+	"testing"
+
+	"github.com/conduitio/conduit-commons/opencdc"
+	"github.com/matryer/is"
+)
+
+func TestProjectPayload(t *testing.T) {
+	is := is.New(t)
+
+	d := &Destination{tables: map[string]TableConfig{
+		"users": {Fields: FieldsConfig{
+			Include:   []string{"login", "email"},
+			Exclude:   []string{"user_pass"},
+			Rename:    map[string]string{"user_login": "login"},
+			Constants: map[string]interface{}{"origin": "wordpress"},
+		}},
+	}}
+
+	r := &opencdc.Record{Payload: opencdc.Change{
+		Before: opencdc.StructuredData{"id": 1, "user_pass": "old"},
+		After: opencdc.StructuredData{
+			"id": 1, "user_login": "admin", "email": "a@example.com", "user_pass": "secret", "user_status": 0,
+		},
+	}}
+	d.projectPayload("users", r)
+	is.Equal(r.Payload.After, opencdc.StructuredData{
+		"id": 1, "login": "admin", "email": "a@example.com", "origin": "wordpress",
+	})
+	is.Equal(r.Payload.Before, opencdc.StructuredData{"id": 1})
+
+	fields := d.projectFields("users", []schemaField{
+		{Name: "id", Type: "int"},
+		{Name: "user_login", Type: "string"},
+		{Name: "user_pass", Type: "string"},
+		{Name: "user_status", Type: "int"},
+	})
+	is.Equal(fields, []schemaField{
+		{Name: "id", Type: "int"},
+		{Name: "login", Type: "string"},
+		{Name: "origin", Type: "any"},
+	})
+}
@@ -0,0 +1,40 @@
+package destination
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/conduitio/conduit-commons/opencdc"
+)
+
+// Ways raw payloads that aren't a JSON object are written.
+const (
+	rawPayloadsFail   = "fail"
+	rawPayloadsBytes  = "bytes"
+	rawPayloadsString = "string"
+)
+
+// storesRawPayloads reports whether raw payloads that aren't a JSON object are
+// stored rather than rejected.
+func (d *Destination) storesRawPayloads() bool {
+	return d.config.RawPayloads == rawPayloadsBytes || d.config.RawPayloads == rawPayloadsString
+}
+
+// wrapRawPayload replaces data with an object holding raw in RawPayloadField,
+// as bytes or as a string. The id is filled in from the key later on.
+func (d *Destination) wrapRawPayload(data *opencdc.Data, raw []byte) error {
+	var value interface{}
+	switch d.config.RawPayloads {
+	case rawPayloadsBytes:
+		value = append([]byte(nil), raw...)
+	case rawPayloadsString:
+		if !utf8.Valid(raw) {
+			return fmt.Errorf("raw payload isn't valid UTF-8, store it as %q instead", rawPayloadsBytes)
+		}
+		value = string(raw)
+	default:
+		return fmt.Errorf("unknown raw payload mode %q", d.config.RawPayloads)
+	}
+	*data = opencdc.StructuredData{d.config.RawPayloadField: value}
+	return nil
+}
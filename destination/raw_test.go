@@ -0,0 +1,39 @@
+package destination
+
+import (
+	"context"
+	"testing"
+
+	"github.com/conduitio/conduit-commons/opencdc"
+	"github.com/matryer/is"
+)
+
+func TestRawPayloads(t *testing.T) {
+	is := is.New(t)
+	d := &Destination{config: Config{FieldNames: fieldNamesKeep, NullValues: nullsKeep, RawPayloads: rawPayloadsString, RawPayloadField: "line"}}
+
+	r := opencdc.Record{
+		Operation: opencdc.OperationCreate,
+		Key:       opencdc.RawData("17"),
+		Payload:   opencdc.Change{After: opencdc.RawData("a,b,c")},
+	}
+	is.NoErr(d.processPayload(context.Background(), &r))
+	is.Equal(r.Payload.After, opencdc.StructuredData{"id": int64(17), "line": "a,b,c"})
+
+	// JSON objects are still written as they are
+	data := opencdc.Data(opencdc.RawData(`{"a": 1}`))
+	is.NoErr(d.structuredDataFormatter(&data))
+	is.Equal(data, opencdc.StructuredData{"a": int64(1)})
+
+	data = opencdc.RawData{0xff, 0x00}
+	is.True(d.structuredDataFormatter(&data) != nil)
+
+	d.config.RawPayloads = rawPayloadsBytes
+	data = opencdc.RawData(`[1, 2]`)
+	is.NoErr(d.structuredDataFormatter(&data))
+	is.Equal(data, opencdc.StructuredData{"line": []byte(`[1, 2]`)})
+
+	d.config.RawPayloads = rawPayloadsFail
+	data = opencdc.RawData(`[1, 2]`)
+	is.True(d.structuredDataFormatter(&data) != nil)
+}
@@ -0,0 +1,517 @@
+package destination
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/conduitio/conduit-commons/opencdc"
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/conduitio/conduit-connector-sdk/schema"
+	"github.com/hamba/avro/v2"
+	"github.com/surrealdb/surrealdb.go"
+)
+
+// schemaField is a field of a table as defined in SurrealDB.
+type schemaField struct {
+	Name string
+	Type string
+	// Unit is the unit numbers of datetime fields are counted in since the
+	// epoch, or zero for milliseconds.
+	Unit time.Duration
+	// Nested holds the fields of record fields.
+	Nested []schemaField
+}
+
+// tableSchema is the payload schema a table was last synced with, and the
+// fields that are defined for it in SurrealDB.
+type tableSchema struct {
+	Subject string
+	Version int
+	Fields  []schemaField
+}
+
+// Schema evolution policies, deciding what happens to breaking changes.
+const (
+	schemaEvolutionApply = "apply"
+	schemaEvolutionLog   = "log"
+	schemaEvolutionFail  = "fail"
+)
+
+// defineSchema keeps the table of r in sync with the payload schema attached to
+// r. The first time a record of the table is seen, the table is defined as
+// SCHEMAFULL with a field for every field of the schema. Whenever the schema
+// version changes afterwards, the new schema is diffed against the fields
+// defined before: new fields and widened types are applied right away, breaking
+// changes according to the SchemaEvolution policy. Records without a schema are
+// left alone.
+func (d *Destination) defineSchema(ctx context.Context, t *target, tableName string, r *opencdc.Record) error {
+	// meta rows are written into their parents
+	if d.tableConfig(tableName).Meta != nil {
+		return nil
+	}
+	subject, version, err := payloadSchemaRef(r)
+	if err != nil || subject == "" {
+		return err
+	}
+	defined, ok := t.schemas[tableName]
+	if ok && defined.Subject == subject && defined.Version == version {
+		return nil
+	}
+
+	desired, err := fetchSchemaFields(ctx, subject, version)
+	if err != nil {
+		return err
+	}
+	desired = d.desiredFields(tableName, desired)
+
+	var query strings.Builder
+	if !ok {
+		fmt.Fprintf(&query, "DEFINE TABLE IF NOT EXISTS %s SCHEMAFULL;\n", escapeIdent(tableName))
+		if err := t.query(query.String()); err != nil {
+			return fmt.Errorf("failed to define table %s: %w", tableName, err)
+		}
+		query.Reset()
+		// the table may have been defined by an earlier run already
+		fields, err := d.definedFields(t, tableName)
+		if err != nil {
+			return err
+		}
+		defined = &tableSchema{Fields: fields}
+	}
+
+	fields := defined.Fields
+	for _, change := range diffFields(defined.Fields, desired) {
+		switch change.Kind {
+		case fieldAdded:
+			fmt.Fprintf(&query, "DEFINE FIELD IF NOT EXISTS %s ON TABLE %s %s;\n", escapeIdent(change.Name), escapeIdent(tableName), fieldType(change.Type))
+		case fieldWidened, fieldRemoved:
+			fmt.Fprintf(&query, "DEFINE FIELD OVERWRITE %s ON TABLE %s %s;\n", escapeIdent(change.Name), escapeIdent(tableName), fieldType(change.Type))
+		case fieldBreaking:
+			msg := fmt.Sprintf("schema %s:%d changes field %s of table %s from %s to %s", subject, version, change.Name, tableName, change.OldType, change.Type)
+			switch d.config.SchemaEvolution {
+			case schemaEvolutionApply:
+				sdk.Logger(ctx).Warn().Msg("Applying breaking change: " + msg)
+				fmt.Fprintf(&query, "DEFINE FIELD OVERWRITE %s ON TABLE %s %s;\n", escapeIdent(change.Name), escapeIdent(tableName), fieldType(change.Type))
+			case schemaEvolutionFail:
+				return fmt.Errorf("breaking schema change: %s", msg)
+			default:
+				sdk.Logger(ctx).Warn().Msg("Ignoring breaking change: " + msg)
+				continue
+			}
+		}
+		fields = withField(fields, schemaField{Name: change.Name, Type: change.Type})
+	}
+	if query.Len() > 0 {
+		if err := t.query(query.String()); err != nil {
+			return fmt.Errorf("failed to define fields of table %s: %w", tableName, err)
+		}
+	}
+
+	t.schemas[tableName] = &tableSchema{Subject: subject, Version: version, Fields: fields}
+	return nil
+}
+
+// desiredFields returns the fields a table is defined with for the fields of
+// its payload schema, the way records are written to it.
+func (d *Destination) desiredFields(tableName string, fields []schemaField) []schemaField {
+	fields = d.normalizeSchemaFieldNames(fields)
+	fields = d.projectFields(tableName, fields)
+	fields = d.dropGeometrySources(tableName, fields)
+	fields = d.overrideFieldTypes(tableName, fields)
+	return append(fields, d.connectorFields(tableName, fields)...)
+}
+
+// connectorFields returns the fields the connector adds to records of a table
+// on top of the ones in its payload schema fields.
+func (d *Destination) connectorFields(tableName string, fields []schemaField) []schemaField {
+	var extra []schemaField
+	if d.config.VersionMetadata != "" && !hasField(fields, d.config.VersionField) {
+		extra = append(extra, schemaField{Name: d.config.VersionField, Type: "any"})
+	}
+	if d.config.MetadataField != "" && !hasField(fields, d.config.MetadataField) {
+		extra = append(extra, schemaField{Name: d.config.MetadataField, Type: "option<object>"})
+	}
+	for field, gc := range d.tableConfig(tableName).Geometry {
+		if !hasField(fields, field) {
+			extra = append(extra, schemaField{Name: field, Type: gc.surrealType()})
+		}
+	}
+	for field, ec := range d.tableConfig(tableName).Embeddings {
+		if !hasField(fields, field) {
+			extra = append(extra, schemaField{Name: field, Type: ec.surrealType()})
+		}
+	}
+	for _, tc := range d.tables {
+		if mc := tc.Meta; mc != nil && mc.Parent == tableName && !hasField(fields, mc.Field) && !hasField(extra, mc.Field) {
+			extra = append(extra, schemaField{Name: mc.Field, Type: "option<object>"})
+		}
+		if ec := tc.Embed; ec != nil && ec.Parent == tableName && !hasField(fields, ec.Field) && !hasField(extra, ec.Field) {
+			typ := "option<array<object>>"
+			if ec.Link {
+				typ = "option<array<record>>"
+			}
+			extra = append(extra, schemaField{Name: ec.Field, Type: typ})
+		}
+	}
+	for _, field := range d.mappedFields(tableName) {
+		if !hasField(fields, field) && !hasField(extra, field) {
+			extra = append(extra, schemaField{Name: field, Type: "string"})
+		}
+	}
+	return extra
+}
+
+// hasField reports whether fields contains a field with the given name.
+func hasField(fields []schemaField, name string) bool {
+	for _, field := range fields {
+		if field.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// withField returns fields with field added, or replacing the field of the
+// same name.
+func withField(fields []schemaField, field schemaField) []schemaField {
+	out := make([]schemaField, 0, len(fields)+1)
+	for _, f := range fields {
+		if f.Name != field.Name {
+			out = append(out, f)
+		}
+	}
+	return append(out, field)
+}
+
+// escapeIdent quotes a table or field name for use in SurrealQL, so that names
+// with characters like "-" or spaces and reserved words can be used.
+func escapeIdent(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "\\`") + "`"
+}
+
+// unescapeIdent returns a name as returned by SurrealDB, which quotes names
+// that need it with ⟨⟩ or backticks, without its quotes.
+func unescapeIdent(name string) string {
+	switch {
+	case strings.HasPrefix(name, "⟨") && strings.HasSuffix(name, "⟩"):
+		return strings.ReplaceAll(strings.TrimSuffix(strings.TrimPrefix(name, "⟨"), "⟩"), "\\⟩", "⟩")
+	case len(name) >= 2 && strings.HasPrefix(name, "`") && strings.HasSuffix(name, "`"):
+		return strings.ReplaceAll(name[1:len(name)-1], "\\`", "`")
+	default:
+		return name
+	}
+}
+
+// definedTypePattern extracts the type from a DEFINE FIELD statement as
+// returned by INFO FOR TABLE.
+var definedTypePattern = regexp.MustCompile(`\bTYPE (.+?)(?: DEFAULT| VALUE| ASSERT| READONLY| PERMISSIONS| COMMENT|$)`)
+
+// definedFields returns the top level fields defined on a table in SurrealDB.
+func (d *Destination) definedFields(t *target, tableName string) ([]schemaField, error) {
+	results, err := surrealdb.Query[map[string]interface{}](t.db, "INFO FOR TABLE "+escapeIdent(tableName), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get fields of table %s: %w", tableName, err)
+	}
+
+	var fields []schemaField
+	for _, result := range *results {
+		definitions, _ := result.Result["fields"].(map[string]interface{})
+		for name, definition := range definitions {
+			name = unescapeIdent(name)
+			// nested fields are covered by the FLEXIBLE object they belong to
+			if strings.ContainsAny(name, ".[") {
+				continue
+			}
+			match := definedTypePattern.FindStringSubmatch(fmt.Sprint(definition))
+			if match == nil {
+				fields = append(fields, schemaField{Name: name, Type: "any"})
+				continue
+			}
+			fields = append(fields, schemaField{Name: name, Type: match[1]})
+		}
+	}
+	return fields, nil
+}
+
+// payloadSchemaRef returns the subject and version of the payload schema
+// attached to r, or an empty subject if there is none.
+func payloadSchemaRef(r *opencdc.Record) (string, int, error) {
+	subject, err := r.Metadata.GetPayloadSchemaSubject()
+	if errors.Is(err, opencdc.ErrMetadataFieldNotFound) {
+		return "", 0, nil
+	}
+	if err != nil {
+		return "", 0, err
+	}
+	version, err := r.Metadata.GetPayloadSchemaVersion()
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to get payload schema version: %w", err)
+	}
+	return subject, version, nil
+}
+
+// fetchSchemaFields fetches a payload schema from the schema registry and maps
+// its fields to SurrealDB types.
+func fetchSchemaFields(ctx context.Context, subject string, version int) ([]schemaField, error) {
+	sch, err := schema.Get(ctx, subject, version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get schema %s:%d: %w", subject, version, err)
+	}
+	avroSchema, err := avro.ParseBytes(sch.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse schema %s:%d: %w", subject, version, err)
+	}
+	recordSchema, ok := avroSchema.(*avro.RecordSchema)
+	if !ok {
+		return nil, fmt.Errorf("schema %s:%d is not a record but %s", subject, version, avroSchema.Type())
+	}
+
+	var fields []schemaField
+	for _, field := range recordSchema.Fields() {
+		// the record id is defined by SurrealDB itself
+		if field.Name() == "id" {
+			continue
+		}
+		fields = append(fields, schemaField{Name: field.Name(), Type: surrealType(field.Type()), Unit: epochUnit(field.Type()), Nested: nestedFields(field.Type())})
+	}
+	return fields, nil
+}
+
+// nestedFields returns the fields of a record, or of the record in a nullable
+// union, and nil for all other schemas.
+func nestedFields(s avro.Schema) []schemaField {
+	switch typed := s.(type) {
+	case *avro.RefSchema:
+		return nestedFields(typed.Schema())
+	case *avro.UnionSchema:
+		var nested []schemaField
+		for _, typ := range typed.Types() {
+			if typ.Type() == avro.Null {
+				continue
+			}
+			if nested != nil {
+				return nil
+			}
+			nested = nestedFields(typ)
+		}
+		return nested
+	case *avro.RecordSchema:
+		fields := make([]schemaField, 0, len(typed.Fields()))
+		for _, field := range typed.Fields() {
+			fields = append(fields, schemaField{Name: field.Name(), Type: surrealType(field.Type()), Unit: epochUnit(field.Type()), Nested: nestedFields(field.Type())})
+		}
+		return fields
+	default:
+		return nil
+	}
+}
+
+// Kinds of changes between the fields defined for a table and a new schema.
+const (
+	fieldAdded = iota
+	fieldWidened
+	fieldRemoved
+	fieldBreaking
+)
+
+// fieldChange is a change to a single field of a table.
+type fieldChange struct {
+	Kind    int
+	Name    string
+	Type    string
+	OldType string
+}
+
+// diffFields compares the fields defined for a table with the desired ones.
+// New fields and types that accept all values of the old type are additive.
+// Fields missing from desired become optional, so records without them are
+// still accepted while existing data stays. Any other type change is breaking.
+func diffFields(defined, desired []schemaField) []fieldChange {
+	definedTypes := make(map[string]string, len(defined))
+	for _, field := range defined {
+		definedTypes[field.Name] = field.Type
+	}
+
+	var changes []fieldChange
+	for _, field := range desired {
+		oldType, ok := definedTypes[field.Name]
+		switch {
+		case !ok:
+			changes = append(changes, fieldChange{Kind: fieldAdded, Name: field.Name, Type: field.Type})
+		case oldType == field.Type:
+		case isWidening(oldType, field.Type):
+			changes = append(changes, fieldChange{Kind: fieldWidened, Name: field.Name, Type: field.Type, OldType: oldType})
+		default:
+			changes = append(changes, fieldChange{Kind: fieldBreaking, Name: field.Name, Type: field.Type, OldType: oldType})
+		}
+	}
+	for _, field := range defined {
+		if hasField(desired, field.Name) {
+			continue
+		}
+		if optional, _ := splitType(field.Type); optional || field.Type == "any" {
+			continue
+		}
+		changes = append(changes, fieldChange{Kind: fieldRemoved, Name: field.Name, Type: optionalType(field.Type), OldType: field.Type})
+	}
+	return changes
+}
+
+// isWidening reports whether every value accepted by oldType is accepted by
+// newType as well.
+func isWidening(oldType, newType string) bool {
+	if newType == "any" {
+		return true
+	}
+	oldOptional, oldKinds := splitType(oldType)
+	newOptional, newKinds := splitType(newType)
+	if oldOptional && !newOptional {
+		return false
+	}
+	for _, o := range oldKinds {
+		covered := false
+		for _, n := range newKinds {
+			if o == n || n == "any" || numericWidening[o+">"+n] {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			return false
+		}
+	}
+	return true
+}
+
+// numericWidening lists the number types that can hold any value of a smaller
+// one, as "from>to".
+var numericWidening = map[string]bool{
+	"int>float":      true,
+	"int>decimal":    true,
+	"int>number":     true,
+	"float>decimal":  true,
+	"float>number":   true,
+	"decimal>number": true,
+}
+
+// splitType splits a SurrealDB type into whether it is optional and the
+// alternatives of its union.
+func splitType(typ string) (bool, []string) {
+	optional := false
+	if strings.HasPrefix(typ, "option<") && strings.HasSuffix(typ, ">") {
+		optional = true
+		typ = strings.TrimSuffix(strings.TrimPrefix(typ, "option<"), ">")
+	}
+	kinds := strings.Split(typ, "|")
+	for i := range kinds {
+		kinds[i] = strings.TrimSpace(kinds[i])
+	}
+	return optional, kinds
+}
+
+// optionalType returns the type of a nullable field of type typ. It accepts
+// NONE, for fields that are left out, as well as NULL.
+func optionalType(typ string) string {
+	return "option<" + typ + " | null>"
+}
+
+// fieldType returns the type clause of a DEFINE FIELD statement. Nested objects
+// are defined as FLEXIBLE, so that their content isn't dropped by the
+// SCHEMAFULL table.
+func fieldType(typ string) string {
+	if strings.Contains(typ, "object") {
+		return "FLEXIBLE TYPE " + typ
+	}
+	return "TYPE " + typ
+}
+
+// epochUnit returns the unit of Avro's date and timestamp-micros types, or of
+// the items or union members of that type, and zero for all other schemas.
+func epochUnit(s avro.Schema) time.Duration {
+	if ls, ok := s.(avro.LogicalTypeSchema); ok && ls.Logical() != nil {
+		switch ls.Logical().Type() {
+		case avro.Date:
+			return 24 * time.Hour
+		case avro.TimestampMicros, avro.LocalTimestampMicros:
+			return time.Microsecond
+		default:
+			return 0
+		}
+	}
+
+	switch typed := s.(type) {
+	case *avro.RefSchema:
+		return epochUnit(typed.Schema())
+	case *avro.ArraySchema:
+		return epochUnit(typed.Items())
+	case *avro.UnionSchema:
+		for _, typ := range typed.Types() {
+			if unit := epochUnit(typ); unit != 0 {
+				return unit
+			}
+		}
+	}
+	return 0
+}
+
+// surrealType maps an Avro type to the matching SurrealDB type.
+func surrealType(s avro.Schema) string {
+	if ls, ok := s.(avro.LogicalTypeSchema); ok && ls.Logical() != nil {
+		switch ls.Logical().Type() {
+		case avro.Decimal:
+			return "decimal"
+		case avro.UUID:
+			return "uuid"
+		case avro.Date, avro.TimestampMillis, avro.TimestampMicros, avro.LocalTimestampMillis, avro.LocalTimestampMicros:
+			return "datetime"
+		case avro.Duration:
+			return "duration"
+		default:
+			// times of day have no SurrealDB equivalent, keep the underlying type
+		}
+	}
+
+	switch typed := s.(type) {
+	case *avro.RefSchema:
+		return surrealType(typed.Schema())
+	case *avro.ArraySchema:
+		return "array<" + surrealType(typed.Items()) + ">"
+	case *avro.UnionSchema:
+		var types []string
+		for _, typ := range typed.Types() {
+			if typ.Type() != avro.Null {
+				types = append(types, surrealType(typ))
+			}
+		}
+		if len(types) == 0 {
+			return "null"
+		}
+		if typed.Nullable() {
+			return optionalType(strings.Join(types, " | "))
+		}
+		return strings.Join(types, " | ")
+	}
+
+	switch s.Type() {
+	case avro.Boolean:
+		return "bool"
+	case avro.Int, avro.Long:
+		return "int"
+	case avro.Float, avro.Double:
+		return "float"
+	case avro.String, avro.Enum:
+		return "string"
+	case avro.Bytes, avro.Fixed:
+		return "bytes"
+	case avro.Record, avro.Map:
+		return "object"
+	case avro.Null:
+		return "null"
+	default:
+		return "any"
+	}
+}
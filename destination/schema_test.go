@@ -0,0 +1,106 @@
+package destination
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hamba/avro/v2"
+	"github.com/matryer/is"
+)
+
+func TestSurrealType(t *testing.T) {
+	is := is.New(t)
+
+	s, err := avro.Parse(`{
+		"type": "record",
+		"name": "wp_posts",
+		"fields": [
+			{"name": "ID", "type": "long"},
+			{"name": "post_title", "type": "string"},
+			{"name": "post_date", "type": {"type": "long", "logicalType": "timestamp-millis"}},
+			{"name": "price", "type": {"type": "bytes", "logicalType": "decimal", "precision": 10, "scale": 2}},
+			{"name": "parent", "type": ["null", "long"]},
+			{"name": "tags", "type": {"type": "array", "items": "string"}},
+			{"name": "extra", "type": ["null", {"type": "map", "values": "string"}]}
+		]
+	}`)
+	is.NoErr(err)
+
+	var got []string
+	for _, field := range s.(*avro.RecordSchema).Fields() {
+		got = append(got, fieldType(surrealType(field.Type())))
+	}
+	is.Equal(got, []string{
+		"TYPE int",
+		"TYPE string",
+		"TYPE datetime",
+		"TYPE decimal",
+		"TYPE option<int | null>",
+		"TYPE array<string>",
+		"FLEXIBLE TYPE option<object | null>",
+	})
+}
+
+func TestEpochUnit(t *testing.T) {
+	is := is.New(t)
+
+	s, err := avro.Parse(`{
+		"type": "record",
+		"name": "events",
+		"fields": [
+			{"name": "created", "type": {"type": "long", "logicalType": "timestamp-millis"}},
+			{"name": "updated", "type": ["null", {"type": "long", "logicalType": "timestamp-micros"}]},
+			{"name": "seen", "type": {"type": "array", "items": {"type": "long", "logicalType": "local-timestamp-micros"}}},
+			{"name": "birthday", "type": {"type": "int", "logicalType": "date"}},
+			{"name": "count", "type": "long"}
+		]
+	}`)
+	is.NoErr(err)
+
+	var got []time.Duration
+	for _, field := range s.(*avro.RecordSchema).Fields() {
+		got = append(got, epochUnit(field.Type()))
+	}
+	is.Equal(got, []time.Duration{0, time.Microsecond, time.Microsecond, 24 * time.Hour, 0})
+}
+
+func TestEscapeIdent(t *testing.T) {
+	is := is.New(t)
+
+	is.Equal(escapeIdent("wp-posts"), "`wp-posts`")
+	is.Equal(escapeIdent("a`b"), "`a\\`b`")
+	is.Equal(unescapeIdent("⟨post date⟩"), "post date")
+	is.Equal(unescapeIdent("`select`"), "select")
+	is.Equal(unescapeIdent(escapeIdent("a`b")), "a`b")
+	is.Equal(unescapeIdent("post_title"), "post_title")
+}
+
+func TestDiffFields(t *testing.T) {
+	is := is.New(t)
+
+	defined := []schemaField{
+		{Name: "post_title", Type: "string"},
+		{Name: "comment_count", Type: "int"},
+		{Name: "post_status", Type: "string"},
+		{Name: "post_parent", Type: "int"},
+		{Name: "guid", Type: "option<string>"},
+	}
+	desired := []schemaField{
+		{Name: "post_title", Type: "string"},
+		{Name: "comment_count", Type: "decimal"},
+		{Name: "post_status", Type: "int"},
+		{Name: "post_excerpt", Type: "option<string>"},
+	}
+
+	is.Equal(diffFields(defined, desired), []fieldChange{
+		{Kind: fieldWidened, Name: "comment_count", Type: "decimal", OldType: "int"},
+		{Kind: fieldBreaking, Name: "post_status", Type: "int", OldType: "string"},
+		{Kind: fieldAdded, Name: "post_excerpt", Type: "option<string>"},
+		{Kind: fieldRemoved, Name: "post_parent", Type: "option<int | null>", OldType: "int"},
+	})
+
+	is.True(isWidening("int", "option<int | string>"))
+	is.True(!isWidening("option<int>", "int"))
+	is.True(isWidening("option<int | null>", "option<float | null>"))
+	is.True(!isWidening("option<int | null>", "option<int>"))
+}
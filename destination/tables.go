@@ -0,0 +1,173 @@
+package destination
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/conduitio/conduit-commons/opencdc"
+	"github.com/surrealdb/surrealdb.go/pkg/models"
+)
+
+// TableConfig holds the settings of a single table, configured in the relations
+// schema file under "tables" and keyed by table name, after mapping.
+type TableConfig struct {
+	// Types maps field names to the SurrealDB type their values are converted
+	// to before writing, e.g. "datetime" or "decimal". It takes precedence over
+	// the types taken from the payload schema.
+	Types map[string]string `yaml:"types"`
+	// Links maps foreign key fields to the table they refer to. Their values are
+	// written as record links to that table, e.g. post_author = 5 becomes
+	// wp_users:5, so that FETCH and traversal with "." work.
+	Links map[string]string `yaml:"links"`
+	// Geometry maps fields to the way they are built as SurrealDB geometries,
+	// from GeoJSON, WKT or a pair of latitude and longitude fields.
+	Geometry map[string]GeometryConfig `yaml:"geometry"`
+	// Embeddings maps fields to the vector embeddings they hold, written as
+	// float arrays of a fixed dimension and optionally indexed for similarity
+	// search.
+	Embeddings map[string]EmbeddingConfig `yaml:"embeddings"`
+	// Indexes are defined on the table in Open, including unique, composite
+	// and full-text search indexes.
+	Indexes []IndexConfig `yaml:"indexes"`
+	// Fields selects, renames and adds the fields written to the table.
+	Fields FieldsConfig `yaml:"fields"`
+	// Meta folds the rows of a meta table into an object field of its parent
+	// records instead of writing them to a table of their own.
+	Meta *MetaConfig `yaml:"meta"`
+	// Embed keeps the records of the table in an array field of their parent
+	// records as well.
+	Embed *EmbedConfig `yaml:"embed"`
+	// Nulls decides how null values are written to the table.
+	Nulls NullsConfig `yaml:"nulls"`
+}
+
+// tableConfig returns the settings of a table, which are empty if the table
+// isn't configured.
+func (d *Destination) tableConfig(tableName string) TableConfig {
+	return d.tables[tableName]
+}
+
+// foldedFields returns the fields of a table that the rows of other tables are
+// folded or embedded into by their meta and embed settings, sorted by name.
+func (d *Destination) foldedFields(tableName string) []string {
+	var fields []string
+	for _, tc := range d.tables {
+		switch {
+		case tc.Meta != nil && tc.Meta.Parent == tableName:
+			fields = append(fields, tc.Meta.Field)
+		case tc.Embed != nil && tc.Embed.Parent == tableName:
+			fields = append(fields, tc.Embed.Field)
+		}
+	}
+	slices.Sort(fields)
+	return slices.Compact(fields)
+}
+
+// content returns the expression that replaces the content of a record of a
+// table with the object in the variable data. The fields other tables are
+// folded into keep their stored value, as payloads of the table don't hold
+// them.
+func (d *Destination) content(tableName, data string) string {
+	fields := d.foldedFields(tableName)
+	if len(fields) == 0 {
+		return data
+	}
+	kept := make([]string, len(fields))
+	for i, field := range fields {
+		kept[i] = field + ": " + field
+	}
+	return fmt.Sprintf("object::from_entries(array::concat(object::entries(%s), object::entries({ %s })))", data, strings.Join(kept, ", "))
+}
+
+// foldDepth returns the number of tables a table is folded into by meta and
+// embed settings before reaching one that isn't, e.g. 2 for the meta rows of
+// embedded comments.
+func (d *Destination) foldDepth(tableName string) int {
+	seen := make(map[string]bool)
+	for depth := 0; ; depth++ {
+		tc := d.tableConfig(tableName)
+		if seen[tableName] {
+			return depth
+		}
+		seen[tableName] = true
+		switch {
+		case tc.Meta != nil:
+			tableName = tc.Meta.Parent
+		case tc.Embed != nil:
+			tableName = tc.Embed.Parent
+		default:
+			return depth
+		}
+	}
+}
+
+// validateTables checks the null, meta and embed settings of all tables and
+// fills in their defaults.
+func (d *Destination) validateTables() error {
+	for tableName, tc := range d.tables {
+		if tc.Meta != nil && tc.Embed != nil {
+			return fmt.Errorf("table %s can't have both meta and embed settings", tableName)
+		}
+		if err := tc.Nulls.validate(); err != nil {
+			return fmt.Errorf("invalid null settings of table %s: %w", tableName, err)
+		}
+		if tc.Meta != nil {
+			if err := tc.Meta.validate(); err != nil {
+				return fmt.Errorf("invalid meta settings of table %s: %w", tableName, err)
+			}
+		}
+		if tc.Embed != nil {
+			if err := tc.Embed.validate(tableName); err != nil {
+				return fmt.Errorf("invalid embed settings of table %s: %w", tableName, err)
+			}
+		}
+	}
+	return nil
+}
+
+// overrideFieldTypes applies the types configured for a table on top of the
+// types of fields taken from its payload schema.
+func (d *Destination) overrideFieldTypes(tableName string, fields []schemaField) []schemaField {
+	tc := d.tableConfig(tableName)
+	out := make([]schemaField, len(fields))
+	for i, field := range fields {
+		optional, _ := splitType(field.Type)
+		gc, isGeometry := tc.Geometry[field.Name]
+		ec, isEmbedding := tc.Embeddings[field.Name]
+		switch {
+		case isGeometry:
+			field.Type = gc.surrealType()
+		case isEmbedding:
+			field.Type = ec.surrealType()
+		case tc.Links[field.Name] != "":
+			field.Type = "record<" + tc.Links[field.Name] + ">"
+			if optional {
+				field.Type = optionalType(field.Type)
+			}
+		case tc.Types[field.Name] != "":
+			field.Type = tc.Types[field.Name]
+			if optional && !strings.HasPrefix(field.Type, "option<") {
+				field.Type = optionalType(field.Type)
+			}
+		}
+		out[i] = field
+	}
+	return out
+}
+
+// applyLinks replaces the values of the link fields of a table in payloadMap
+// with record links. Values that are record links already are left untouched.
+func (d *Destination) applyLinks(tableName string, payloadMap opencdc.StructuredData) {
+	for field, linkedTable := range d.tableConfig(tableName).Links {
+		value, ok := payloadMap[field]
+		if !ok || value == nil {
+			continue
+		}
+		switch value.(type) {
+		case models.RecordID, *models.RecordID:
+			continue
+		}
+		payloadMap[field] = models.NewRecordID(linkedTable, value)
+	}
+}
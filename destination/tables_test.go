@@ -0,0 +1,68 @@
+package destination
+
+import (
+	"testing"
+
+	"github.com/conduitio/conduit-commons/opencdc"
+	"github.com/matryer/is"
+	"github.com/surrealdb/surrealdb.go/pkg/models"
+)
+
+func TestApplyLinks(t *testing.T) {
+	is := is.New(t)
+
+	d := &Destination{
+		tables: map[string]TableConfig{
+			"wp_posts": {Links: map[string]string{
+				"post_author": "wp_users",
+				"post_parent": "wp_posts",
+				"guid_link":   "wp_links",
+				"term":        "wp_terms",
+			}},
+		},
+	}
+
+	existing := models.NewRecordID("wp_terms", "news")
+	payload := opencdc.StructuredData{
+		"id":          int64(1),
+		"post_author": int64(5),
+		"post_parent": nil,
+		"term":        existing,
+		"title":       "hi",
+	}
+	d.applyLinks("wp_posts", payload)
+	is.Equal(payload, opencdc.StructuredData{
+		"id":          int64(1),
+		"post_author": models.NewRecordID("wp_users", int64(5)),
+		"post_parent": nil,
+		"term":        existing,
+		"title":       "hi",
+	})
+
+	// tables without links are left alone
+	payload = opencdc.StructuredData{"id": int64(5), "post_author": int64(5)}
+	d.applyLinks("wp_users", payload)
+	is.Equal(payload, opencdc.StructuredData{"id": int64(5), "post_author": int64(5)})
+}
+
+func TestFoldedTables(t *testing.T) {
+	is := is.New(t)
+	d := &Destination{tables: map[string]TableConfig{
+		"wp_postmeta":    {Meta: &MetaConfig{Parent: "wp_posts", Field: "meta"}},
+		"wp_comments":    {Embed: &EmbedConfig{Parent: "wp_posts", Field: "comments"}},
+		"wp_commentmeta": {Meta: &MetaConfig{Parent: "wp_comments", Field: "meta"}},
+	}}
+
+	is.Equal(d.foldedFields("wp_posts"), []string{"comments", "meta"})
+	is.Equal(d.foldedFields("wp_users"), []string(nil))
+	is.Equal(d.content("wp_users", "$data"), "$data")
+	is.Equal(d.content("wp_comments", "$data"), "object::from_entries(array::concat(object::entries($data), object::entries({ meta: meta })))")
+
+	// meta rows and children come after the tables they are folded into
+	tg := &target{}
+	parents, folded := d.splitFolded([]tableGroup{
+		{tg, "wp_commentmeta"}, {tg, "wp_postmeta"}, {tg, "wp_comments"}, {tg, "wp_posts"},
+	})
+	is.Equal(parents, []tableGroup{{tg, "wp_posts"}})
+	is.Equal(folded, []tableGroup{{tg, "wp_postmeta"}, {tg, "wp_comments"}, {tg, "wp_commentmeta"}})
+}
@@ -0,0 +1,198 @@
+package destination
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sync"
+	"text/template"
+
+	"github.com/conduitio/conduit-commons/opencdc"
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/surrealdb/surrealdb.go"
+)
+
+// targetNamePattern matches the namespace and database names records can be
+// routed to. It keeps names taken from records from breaking out of the
+// statements they are used in.
+var targetNamePattern = regexp.MustCompile(`^\w+$`)
+
+// target is a namespace and database records are written to, with its own
+// connection and the state the connector keeps about the tables in it.
+type target struct {
+	namespace string
+	database  string
+	db        *surrealdb.DB
+	token     string
+
+	// schemas holds the schema each table was defined with, by table name.
+	schemas map[string]*tableSchema
+	// checkpoints holds the position of the last record written per source and
+	// table, guarded by checkpointsMu as tables are written concurrently.
+	checkpoints   map[string]opencdc.Position
+	checkpointsMu sync.Mutex
+}
+
+// targetKey identifies the target of a namespace and database.
+func targetKey(namespace, database string) string {
+	return namespace + "/" + database
+}
+
+// connect opens a connection to a namespace and database, defines them first
+// if CreateTargets is enabled, and prepares the new target for writing.
+func (d *Destination) connect(ctx context.Context, namespace, database string) (*target, error) {
+	sdk.Logger(ctx).Info().Msg(fmt.Sprintf("Connecting to SurrealDB... on %s, namespace %s, database %s", d.config.URL, namespace, database))
+
+	db, err := surrealdb.New(d.config.URL)
+	if err != nil {
+		sdk.Logger(ctx).Error().Msg("Failed to create SurrealDB client: " + err.Error())
+		return nil, fmt.Errorf("failed to create SurrealDB client: %w", err)
+	}
+
+	if err = db.Use(namespace, database); err != nil {
+		sdk.Logger(ctx).Error().Msg("Failed to select namespace and database: " + err.Error())
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to select namespace and database: %w", err)
+	}
+
+	authData := &surrealdb.Auth{
+		Username: d.config.Username,
+		Password: d.config.Password,
+	}
+
+	token, err := db.SignIn(authData)
+	if err != nil {
+		sdk.Logger(ctx).Error().Msg("Failed to sign in to SurrealDB: " + err.Error())
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to sign in to SurrealDB: %w", err)
+	}
+
+	t := &target{
+		namespace: namespace,
+		database:  database,
+		db:        db,
+		token:     token,
+		schemas:   make(map[string]*tableSchema),
+	}
+	if d.config.CreateTargets {
+		if err := t.query(fmt.Sprintf("DEFINE NAMESPACE IF NOT EXISTS `%s`; DEFINE DATABASE IF NOT EXISTS `%s`;", namespace, database)); err != nil {
+			_ = db.Close()
+			return nil, fmt.Errorf("failed to define namespace %s and database %s: %w", namespace, database, err)
+		}
+	}
+	if err := d.prepareTarget(t); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return t, nil
+}
+
+// prepareTarget applies the relations, fields and indexes of the relations
+// schema to a target and loads its checkpoints.
+func (d *Destination) prepareTarget(t *target) error {
+	for _, config := range d.relations {
+		if err := createRelationEvent(t.db, config, d.config.MetadataField); err != nil {
+			fmt.Printf("failed to create relation %s: %v\n", config.Name, err)
+		}
+	}
+	if err := d.defineGeometryFields(t); err != nil {
+		return err
+	}
+	if err := d.defineEmbeddingFields(t); err != nil {
+		return err
+	}
+	if err := d.defineIndexes(t); err != nil {
+		return err
+	}
+	if d.config.Checkpoints {
+		if err := d.loadCheckpoints(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compileRoutes parses the templates records are routed with.
+func (d *Destination) compileRoutes() error {
+	var err error
+	if d.config.RouteNamespace != "" {
+		if d.routeNamespace, err = template.New("namespace").Funcs(mappingFuncs).Parse(d.config.RouteNamespace); err != nil {
+			return fmt.Errorf("invalid %s: %w", ConfigRouteNamespace, err)
+		}
+	}
+	if d.config.RouteDatabase != "" {
+		if d.routeDatabase, err = template.New("database").Funcs(mappingFuncs).Parse(d.config.RouteDatabase); err != nil {
+			return fmt.Errorf("invalid %s: %w", ConfigRouteDatabase, err)
+		}
+	}
+	return nil
+}
+
+// route returns the target r is written to, connecting to it the first time it
+// is used. Without routing, every record goes to the configured namespace and
+// database.
+func (d *Destination) route(ctx context.Context, tableName string, r opencdc.Record) (*target, error) {
+	if d.routeNamespace == nil && d.routeDatabase == nil {
+		return d.target, nil
+	}
+
+	payloadMap, _ := r.Payload.After.(opencdc.StructuredData)
+	data := struct {
+		Metadata opencdc.Metadata
+		Payload  opencdc.StructuredData
+		Table    string
+	}{Metadata: r.Metadata, Payload: payloadMap, Table: tableName}
+
+	namespace, database := d.config.Namespace, d.config.Database
+	var err error
+	if d.routeNamespace != nil {
+		if namespace, err = executeTemplate(d.routeNamespace, data); err != nil {
+			return nil, fmt.Errorf("failed to route record to a namespace: %w", err)
+		}
+		if !targetNamePattern.MatchString(namespace) {
+			return nil, fmt.Errorf("record routed to invalid namespace %q", namespace)
+		}
+	}
+	if d.routeDatabase != nil {
+		if database, err = executeTemplate(d.routeDatabase, data); err != nil {
+			return nil, fmt.Errorf("failed to route record to a database: %w", err)
+		}
+		if !targetNamePattern.MatchString(database) {
+			return nil, fmt.Errorf("record routed to invalid database %q", database)
+		}
+	}
+
+	key := targetKey(namespace, database)
+	if t, ok := d.targets[key]; ok {
+		return t, nil
+	}
+	t, err := d.connect(ctx, namespace, database)
+	if err != nil {
+		return nil, err
+	}
+	d.targets[key] = t
+	return t, nil
+}
+
+// query runs a statement on the target that returns nothing of interest and
+// fails if any of its results is an error.
+func (t *target) query(sql string) error {
+	results, err := surrealdb.Query[interface{}](t.db, sql, nil)
+	if err != nil {
+		return err
+	}
+	for _, result := range *results {
+		if result.Status != "OK" {
+			return fmt.Errorf("%v", result.Result)
+		}
+	}
+	return nil
+}
+
+// close invalidates the token of the target and closes its connection.
+func (t *target) close() error {
+	if err := t.db.Invalidate(); err != nil {
+		return fmt.Errorf("failed to invalidate token: %w", err)
+	}
+	return t.db.Close()
+}
@@ -0,0 +1,31 @@
+package destination
+
+import (
+	"context"
+	"testing"
+
+	"github.com/conduitio/conduit-commons/opencdc"
+	"github.com/matryer/is"
+)
+
+func TestRoute(t *testing.T) {
+	is := is.New(t)
+
+	d := &Destination{}
+	d.config.Namespace = "wp"
+	d.config.Database = "main"
+	d.config.RouteDatabase = `site_{{.Payload.site}}`
+	is.NoErr(d.compileRoutes())
+
+	site2 := &target{namespace: "wp", database: "site_2"}
+	d.targets = map[string]*target{targetKey("wp", "site_2"): site2}
+
+	rec := opencdc.Record{Payload: opencdc.Change{After: opencdc.StructuredData{"site": "2"}}}
+	got, err := d.route(context.Background(), "posts", rec)
+	is.NoErr(err)
+	is.Equal(got, site2)
+
+	rec.Payload.After = opencdc.StructuredData{"site": "2; REMOVE DATABASE main"}
+	_, err = d.route(context.Background(), "posts", rec)
+	is.True(err != nil)
+}
@@ -0,0 +1,135 @@
+package destination
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/conduitio/conduit-commons/opencdc"
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/surrealdb/surrealdb.go"
+)
+
+// setVersion makes sure the payload of r carries its version in the configured
+// VersionField, taking it from the configured metadata key if there is one.
+// Deletes usually have no payload, so their version is looked up in the
+// payload before the change as well.
+func (d *Destination) setVersion(r *opencdc.Record) error {
+	afterMap, ok := r.Payload.After.(opencdc.StructuredData)
+	if !ok {
+		return fmt.Errorf("unexpected type for r.Payload.After: %T", r.Payload.After)
+	}
+
+	if d.config.VersionMetadata != "" {
+		value, ok := r.Metadata[d.config.VersionMetadata]
+		if !ok || value == "" {
+			return fmt.Errorf("record is missing version metadata %q", d.config.VersionMetadata)
+		}
+		// timestamps like opencdc.readAt are unix nanoseconds, which only
+		// compare correctly as numbers
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			afterMap[d.config.VersionField] = n
+		} else {
+			afterMap[d.config.VersionField] = value
+		}
+		return nil
+	}
+
+	if version, ok := afterMap[d.config.VersionField]; ok && version != nil {
+		return nil
+	}
+	if err := d.structuredDataFormatter(&r.Payload.Before); err != nil {
+		return fmt.Errorf("failed to get payload before the change: %w", err)
+	}
+	if beforeMap, ok := r.Payload.Before.(opencdc.StructuredData); ok {
+		if version, ok := beforeMap[d.config.VersionField]; ok && version != nil {
+			afterMap[d.config.VersionField] = version
+			return nil
+		}
+	}
+	return fmt.Errorf("record is missing version field %q", d.config.VersionField)
+}
+
+// versionedWrite applies a run of creates, updates or deletes only where the
+// incoming version is newer than the version stored in SurrealDB, or for
+// deletes at least as new, so that replayed or out-of-order changes never
+// overwrite newer data. Records without a stored version are always written.
+// All statements are sent in a single query, and it reports which payloads were
+// written, whether applied or skipped as stale, and which of them were applied.
+// Deletes of records that don't exist count as skipped.
+func (d *Destination) versionedWrite(ctx context.Context, t *target, tableName string, operation opencdc.Operation, payloads []*opencdc.Data) ([]bool, []bool, error) {
+	landed := make([]bool, len(payloads))
+	applied := make([]bool, len(payloads))
+
+	var query strings.Builder
+	vars := map[string]interface{}{"tb": tableName}
+	if err := d.versionedStatements(&query, vars, tableName, operation, payloads); err != nil {
+		return landed, applied, err
+	}
+
+	results, err := surrealdb.Query[interface{}](t.db, query.String(), vars)
+	if err != nil {
+		sdk.Logger(ctx).Error().Msg("Failed to write versioned records: " + err.Error())
+		return landed, applied, fmt.Errorf("failed to write versioned records: %w", err)
+	}
+
+	var errs []error
+	skipped := 0
+	for i, result := range *results {
+		if result.Status != "OK" {
+			errs = append(errs, fmt.Errorf("record %s: %w", recordName(tableName, payloads[i]), statementError{result.Result}))
+			continue
+		}
+		landed[i] = true
+		if rows, ok := result.Result.([]interface{}); ok && len(rows) == 0 {
+			skipped++
+			continue
+		}
+		applied[i] = true
+	}
+	if skipped > 0 {
+		sdk.Logger(ctx).Debug().Msg(fmt.Sprintf("Skipped %d stale records in table %s", skipped, tableName))
+	}
+
+	return landed, applied, errors.Join(errs...)
+}
+
+// versionedStatements appends one conditional statement per payload to query,
+// writing into tableName, given by $tb, and adds the variables they use to vars.
+func (d *Destination) versionedStatements(query *strings.Builder, vars map[string]interface{}, tableName string, operation opencdc.Operation, payloads []*opencdc.Data) error {
+	field := d.config.VersionField
+	for i, payload := range payloads {
+		payloadMap, ok := (*payload).(opencdc.StructuredData)
+		if !ok {
+			return fmt.Errorf("unexpected type for payload: %T", *payload)
+		}
+		vars[fmt.Sprintf("id%d", i)] = payloadMap["id"]
+		vars[fmt.Sprintf("version%d", i)] = payloadMap[field]
+
+		if operation == opencdc.OperationDelete {
+			// deletes usually carry the version of the payload before the
+			// change, which is the stored version itself
+			fmt.Fprintf(query, "DELETE type::thing($tb, $id%[1]d) WHERE %[2]s = NONE OR %[2]s <= $version%[1]d RETURN BEFORE;\n", i, field)
+			continue
+		}
+
+		condition := fmt.Sprintf("WHERE %[1]s = NONE OR %[1]s < $version%[2]d", field, i)
+		vars[fmt.Sprintf("data%d", i)] = withoutID(payloadMap)
+		fmt.Fprintf(query, "UPSERT type::thing($tb, $id%d) CONTENT %s %s;\n", i, d.content(tableName, fmt.Sprintf("$data%d", i)), condition)
+	}
+	return nil
+}
+
+// withoutID returns a copy of payloadMap without its "id" field, which is part
+// of the record name already and must not be in the content of a statement.
+func withoutID(payloadMap opencdc.StructuredData) map[string]interface{} {
+	data := make(map[string]interface{}, len(payloadMap))
+	for k, v := range payloadMap {
+		if k != "id" {
+			data[k] = v
+		}
+	}
+	return data
+}
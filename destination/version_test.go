@@ -0,0 +1,87 @@
+package destination
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/conduitio/conduit-commons/opencdc"
+	"github.com/matryer/is"
+)
+
+func TestSetVersion(t *testing.T) {
+	is := is.New(t)
+	d := &Destination{config: Config{VersionField: "updated_at"}}
+
+	// the version in the payload is kept
+	r := opencdc.Record{Payload: opencdc.Change{After: opencdc.StructuredData{"id": 1, "updated_at": int64(7)}}}
+	is.NoErr(d.setVersion(&r))
+	is.Equal(r.Payload.After, opencdc.StructuredData{"id": 1, "updated_at": int64(7)})
+
+	// deletes take it from the raw payload before the change
+	r = opencdc.Record{
+		Operation: opencdc.OperationDelete,
+		Payload: opencdc.Change{
+			Before: opencdc.RawData(`{"id": 1, "updated_at": 9}`),
+			After:  opencdc.StructuredData{"id": 1},
+		},
+	}
+	is.NoErr(d.setVersion(&r))
+	is.Equal(r.Payload.After, opencdc.StructuredData{"id": 1, "updated_at": int64(9)})
+
+	r = opencdc.Record{Payload: opencdc.Change{After: opencdc.StructuredData{"id": 1}}}
+	is.True(d.setVersion(&r) != nil)
+
+	// metadata versions are numbers if they can be
+	d.config.VersionMetadata = "opencdc.readAt"
+	r = opencdc.Record{
+		Metadata: opencdc.Metadata{"opencdc.readAt": "1700000000000000000"},
+		Payload:  opencdc.Change{After: opencdc.StructuredData{"id": 1, "updated_at": int64(7)}},
+	}
+	is.NoErr(d.setVersion(&r))
+	is.Equal(r.Payload.After, opencdc.StructuredData{"id": 1, "updated_at": int64(1700000000000000000)})
+
+	r = opencdc.Record{Payload: opencdc.Change{After: opencdc.StructuredData{"id": 1}}}
+	is.True(d.setVersion(&r) != nil)
+}
+
+func TestVersionedStatements(t *testing.T) {
+	is := is.New(t)
+	d := &Destination{config: Config{VersionField: "updated_at"}}
+
+	data := func(m opencdc.StructuredData) *opencdc.Data {
+		var d opencdc.Data = m
+		return &d
+	}
+
+	var query strings.Builder
+	vars := make(map[string]interface{})
+	is.NoErr(d.versionedStatements(&query, vars, "wp_posts", opencdc.OperationUpdate, []*opencdc.Data{
+		data(opencdc.StructuredData{"id": 1, "updated_at": 7, "title": "a"}),
+	}))
+	is.Equal(query.String(), "UPSERT type::thing($tb, $id0) CONTENT $data0 WHERE updated_at = NONE OR updated_at < $version0;\n")
+	is.Equal(vars, map[string]interface{}{
+		"id0":      1,
+		"version0": 7,
+		"data0":    map[string]interface{}{"updated_at": 7, "title": "a"},
+	})
+
+	// deletes carry the stored version, so they apply to equal versions as well
+	query.Reset()
+	vars = make(map[string]interface{})
+	is.NoErr(d.versionedStatements(&query, vars, "wp_posts", opencdc.OperationDelete, []*opencdc.Data{
+		data(opencdc.StructuredData{"id": 1, "updated_at": 7}),
+		data(opencdc.StructuredData{"id": 2, "updated_at": 3}),
+	}))
+	is.Equal(query.String(), "DELETE type::thing($tb, $id0) WHERE updated_at = NONE OR updated_at <= $version0 RETURN BEFORE;\n"+
+		"DELETE type::thing($tb, $id1) WHERE updated_at = NONE OR updated_at <= $version1 RETURN BEFORE;\n")
+	is.Equal(vars, map[string]interface{}{"id0": 1, "version0": 7, "id1": 2, "version1": 3})
+}
+
+func TestValidateVersionField(t *testing.T) {
+	is := is.New(t)
+
+	is.NoErr(Config{VersionField: "updated_at"}.Validate())
+	// the field is part of the statements, so it must be a plain name
+	is.True(Config{VersionField: "v = NONE; DELETE wp_posts; --"}.Validate() != nil)
+	is.True(Config{VersionMetadata: "opencdc.readAt"}.Validate() != nil)
+}